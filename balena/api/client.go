@@ -0,0 +1,169 @@
+// Package api is a typed client of the Pine/OData API of Balena
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"net/http"
+	"time"
+)
+
+// Client sends authenticated requests to a Balena API
+type Client struct {
+	http         *resty.Client
+	ctx          context.Context
+	maxRetries   int
+	maxRetryWait time.Duration
+}
+
+// Settings tune how the client copes with a busy Balena API
+type Settings struct {
+	// MaxRetries is how often a request failing with 429, a 5xx or a network error is retried
+	MaxRetries int
+	// MaxRetryWait caps the wait before a retry, including waits Balena asks for in `Retry-After`
+	MaxRetryWait time.Duration
+	// RequestsPerSecond is the rate requests are sent at on average, or 0 for no limit
+	RequestsPerSecond float64
+	// RequestBurst is how many requests may be sent at once after a quiet period, despite RequestsPerSecond
+	RequestBurst int
+	// MaxConcurrentRequests caps the requests waiting for a response, or 0 for no cap
+	MaxConcurrentRequests int
+	// Context cancels the requests in progress, including their waits for the limits, e.g. when Terraform
+	// is interrupted. Requests are not cancelled when it is nil
+	Context context.Context
+}
+
+// Response is the envelope Balena wraps the results of a query in
+type Response[T any] struct {
+	D []T `json:"d"`
+}
+
+// Error is returned for any response outside of the 2xx range
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+	// RetryAfter is the wait Balena asked for in `Retry-After`, e.g. when rate limiting, or 0
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s failed with statuscode %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+}
+
+// StatusCode returns the status code of an Error, or 0 for any other error
+func StatusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+func IsNotFound(err error) bool {
+	return StatusCode(err) == 404
+}
+
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == 401 || StatusCode(err) == 403
+}
+
+// NewClient initializes a client authenticating with a session token or API key. Idempotent requests
+// are retried by resty itself, posts only through RetryCreate
+func NewClient(baseURL string, token string, settings Settings) *Client {
+	transport := &limitedTransport{next: http.DefaultTransport}
+	if settings.RequestsPerSecond > 0 {
+		transport.bucket = newTokenBucket(settings.RequestsPerSecond, settings.RequestBurst)
+	}
+	if settings.MaxConcurrentRequests > 0 {
+		transport.inFlight = make(semaphore, settings.MaxConcurrentRequests)
+	}
+
+	c := resty.New().
+		SetTransport(transport).
+		SetBaseURL(baseURL).
+		SetHeader("Authorization", fmt.Sprintf("Bearer %s", token)).
+		SetRetryCount(settings.MaxRetries).
+		SetRetryWaitTime(min(retryBaseWait, settings.MaxRetryWait)).
+		SetRetryMaxWaitTime(settings.MaxRetryWait).
+		SetRetryAfter(retryAfter).
+		AddRetryCondition(retryCondition)
+
+	ctx := settings.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &Client{http: c, ctx: ctx, maxRetries: settings.MaxRetries, maxRetryWait: settings.MaxRetryWait}
+}
+
+// do sends a request, decoding a successful response into out when it is not nil
+func (c *Client) do(method string, path string, body interface{}, out interface{}) error {
+	req := c.http.R().SetContext(c.ctx)
+	if body != nil {
+		req.SetBody(body)
+	}
+
+	res, err := req.Execute(method, path)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode() < 200 || res.StatusCode() >= 300 {
+		return &Error{
+			Method:     method,
+			Path:       path,
+			StatusCode: res.StatusCode(),
+			Body:       res.String(),
+			RetryAfter: parseRetryAfter(res.Header().Get("Retry-After")),
+		}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(res.Body(), out); err != nil {
+			return fmt.Errorf("failed to unmarshal response from %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// Get runs a query and returns the objects of the response
+func Get[T any](c *Client, q *Query) ([]T, error) {
+	var response Response[T]
+	if err := c.do(resty.MethodGet, q.String(), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.D, nil
+}
+
+// Create posts a new object to a resource set and returns it, as Balena does not wrap created objects
+func Create[T any](c *Client, r Resource, body map[string]interface{}) (*T, error) {
+	var created T
+	if err := c.do(resty.MethodPost, r.All().Path(), body, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Patch updates the fields in body of the objects addressed by the query
+func (c *Client) Patch(q *Query, body map[string]interface{}) error {
+	return c.do(resty.MethodPatch, q.String(), body, nil)
+}
+
+// Delete deletes the objects addressed by the query
+func (c *Client) Delete(q *Query) error {
+	return c.do(resty.MethodDelete, q.String(), nil, nil)
+}
+
+// GetJSON requests one of the endpoints of Balena outside of the resource sets, e.g. `/actor/v1/whoami`
+func (c *Client) GetJSON(path string, out interface{}) error {
+	return c.do(resty.MethodGet, path, nil, out)
+}
+
+// PostJSON posts to one of the endpoints of Balena outside of the resource sets, e.g. `/api-key/user/full`
+func (c *Client) PostJSON(path string, body interface{}, out interface{}) error {
+	return c.do(resty.MethodPost, path, body, out)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Filter is an OData `$filter` expression. Literal values are escaped when the filter is built,
+// so a filter is safe to embed in a query regardless of the values it compares
+type Filter string
+
+func (f Filter) String() string {
+	return string(f)
+}
+
+// Literal formats a value as an OData literal: strings are quoted with embedded quotes doubled
+// and percent-encoded, so that neither `'` nor `&` can end the literal or the query option.
+// Any other kind of value than nil, a string, a bool or a number is a programming error and panics
+func Literal(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return "'" + escape(strings.ReplaceAll(v.String(), "'", "''")) + "'"
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	default:
+		panic(fmt.Sprintf("unsupported OData literal %v of type %T", value, value))
+	}
+}
+
+// escape percent-encodes a value like JavaScript's encodeURIComponent, which Pine decodes
+func escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func compare(field string, operator string, value interface{}) Filter {
+	return Filter(fmt.Sprintf("%s %s %s", field, operator, Literal(value)))
+}
+
+// Eq matches objects whose field, e.g. `slug` or `organization/handle`, equals the value
+func Eq(field string, value interface{}) Filter {
+	return compare(field, "eq", value)
+}
+
+func Ne(field string, value interface{}) Filter {
+	return compare(field, "ne", value)
+}
+
+func Lt(field string, value interface{}) Filter {
+	return compare(field, "lt", value)
+}
+
+func Gt(field string, value interface{}) Filter {
+	return compare(field, "gt", value)
+}
+
+// StartsWith matches objects whose string field starts with the prefix
+func StartsWith(field string, prefix string) Filter {
+	return Filter(fmt.Sprintf("startswith(%s,%s)", field, Literal(prefix)))
+}
+
+// Any matches objects with at least one related object, through the navigation, matching the filter.
+// The filter refers to the related object through the alias, e.g. `Any("device_tag", "t", Eq("t/tag_key", key))`
+func Any(navigation string, alias string, filter Filter) Filter {
+	return Filter(fmt.Sprintf("%s/any(%s:%s)", navigation, alias, filter))
+}
+
+// And combines filters, ignoring empty ones
+func And(filters ...Filter) Filter {
+	return join(" and ", filters)
+}
+
+// Or combines filters, ignoring empty ones. The result is parenthesized so that it can be combined with And
+func Or(filters ...Filter) Filter {
+	combined := join(" or ", filters)
+	if strings.Contains(string(combined), " or ") {
+		return "(" + combined + ")"
+	}
+	return combined
+}
+
+func Not(filter Filter) Filter {
+	return Filter(fmt.Sprintf("not(%s)", filter))
+}
+
+func join(operator string, filters []Filter) Filter {
+	var parts []string
+	for _, filter := range filters {
+		if filter != "" {
+			parts = append(parts, string(filter))
+		}
+	}
+	return Filter(strings.Join(parts, operator))
+}
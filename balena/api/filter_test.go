@@ -0,0 +1,141 @@
+package api
+
+import (
+	"testing"
+)
+
+type myInt int
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, "null"},
+		{"string", "my-fleet", "'my-fleet'"},
+		{"quote", "it's", "'it%27%27s'"},
+		{"quote closing the literal", "x' or 1 eq 1 or 'x", "'x%27%27%20or%201%20eq%201%20or%20%27%27x'"},
+		{"ampersand", "a&$top=1", "'a%26%24top%3D1'"},
+		{"plus", "a+b", "'a%2Bb'"},
+		{"percent", "100%", "'100%25'"},
+		{"space", "a b", "'a%20b'"},
+		{"slash", "a/b", "'a%2Fb'"},
+		{"empty", "", "''"},
+		{"true", true, "true"},
+		{"false", false, "false"},
+		{"int", 5, "5"},
+		{"negative int", -5, "-5"},
+		{"int32", int32(7), "7"},
+		{"int64", int64(1) << 40, "1099511627776"},
+		{"named int", myInt(3), "3"},
+		{"uint", uint(5), "5"},
+		{"uint8", uint8(255), "255"},
+		{"uint64", uint64(18446744073709551615), "18446744073709551615"},
+		{"float32", float32(1.5), "1.5"},
+		{"float64", 0.1, "0.1"},
+		{"integral float", 5.0, "5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Literal(tt.value); got != tt.want {
+				t.Errorf("Literal(%#v) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiteralRejectsUnsupportedTypes(t *testing.T) {
+	for _, value := range []interface{}{[]string{"a"}, struct{}{}, map[string]int{}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Literal(%#v) did not panic", value)
+				}
+			}()
+			Literal(value)
+		}()
+	}
+}
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   Filter
+	}{
+		{"eq", Eq("slug", "org/fleet"), "slug eq 'org%2Ffleet'"},
+		{"eq nil", Ne("name", nil), "name ne null"},
+		{"lt", Lt("id", 10), "id lt 10"},
+		{"gt", Gt("id", uint(10)), "id gt 10"},
+		{"startswith", StartsWith("commit", "ab'c"), "startswith(commit,'ab%27%27c')"},
+		{"any", Any("device_tag", "t", Eq("t/tag_key", "a&b")), "device_tag/any(t:t/tag_key eq 'a%26b')"},
+		{"not", Not(Eq("is_final", true)), "not(is_final eq true)"},
+		{"and", And(Eq("a", 1), Eq("b", 2)), "a eq 1 and b eq 2"},
+		{"and skips empty filters", And("", Eq("a", 1), ""), "a eq 1"},
+		{"and of nothing", And(), ""},
+		{"or", Or(Eq("a", 1), Eq("b", 2)), "(a eq 1 or b eq 2)"},
+		{"or of one filter", Or(Eq("a", 1), ""), "a eq 1"},
+		{
+			"and of or",
+			And(Or(Eq("a", 1), Eq("b", "x y")), Eq("c", true)),
+			"(a eq 1 or b eq 'x%20y') and c eq true",
+		},
+		{
+			"nested",
+			Or(And(Eq("a", 1), Or(Eq("b", 2), Eq("c", 3))), Not(Eq("d", "it's"))),
+			"(a eq 1 and (b eq 2 or c eq 3) or not(d eq 'it%27%27s'))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.filter != tt.want {
+				t.Errorf("got %s, want %s", tt.filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *Query
+		want  string
+	}{
+		{"all", Application.All(), "/v7/application"},
+		{"id", Application.ID(5), "/v7/application(5)"},
+		{"key", Device.Key("uuid", "ab'c"), "/v7/device(uuid='ab%27%27c')"},
+		{
+			"filter",
+			Application.All().Filter(Eq("slug", "org/my fleet&x")),
+			"/v7/application?$filter=slug%20eq%20'org%2Fmy%20fleet%26x'",
+		},
+		{
+			"filters are combined",
+			ApplicationTag.All().Filter(Eq("application", 1)).Filter(Or(Eq("tag_key", "a"), Eq("tag_key", "b"))),
+			"/v7/application_tag?$filter=application%20eq%201%20and%20(tag_key%20eq%20'a'%20or%20tag_key%20eq%20'b')",
+		},
+		{
+			"all options",
+			Release.All().
+				Select("id", "commit").
+				Expand("release_tag", Options().Select("tag_key", "value").Filter(Eq("tag_key", "a+b"))).
+				Filter(Eq("belongs_to__application", 1)).
+				OrderBy("created_at desc").
+				Top(2),
+			"/v7/release?$select=id,commit&$expand=release_tag($select=tag_key,value;$filter=tag_key%20eq%20'a%2Bb')" +
+				"&$filter=belongs_to__application%20eq%201&$orderby=created_at%20desc&$top=2",
+		},
+		{"expand without options", Device.All().Expand("belongs_to__application", nil), "/v7/device?$expand=belongs_to__application"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// limitedTransport holds every attempt at a request, including retries, to the rate limit and the cap
+// on requests in flight. Either is disabled when nil. A request is in flight from when it is sent until
+// its response body is closed, which resty does once it has read the body. The wait before a retry does
+// not count as in flight, and a request whose context is cancelled stops waiting for the limits
+type limitedTransport struct {
+	next     http.RoundTripper
+	bucket   *tokenBucket
+	inFlight semaphore
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.bucket != nil {
+		if err := t.bucket.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if t.inFlight == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	if err := t.inFlight.acquire(ctx); err != nil {
+		return nil, err
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.inFlight.release()
+		return nil, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: t.inFlight.release}
+	return res, nil
+}
+
+// releasingBody frees the slot of a request in flight once its response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// tokenBucket limits the rate of requests: it holds up to burst tokens, refilled at rate tokens per second,
+// and every request takes one. Requests finding the bucket empty reserve a future token and wait for it,
+// so they are served in the order they arrive
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token at the time now, returning how long to wait until it is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that is no longer needed
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// wait blocks until a token is available and takes it, or until the context is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	delay := b.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+// semaphore caps the number of requests in flight at its capacity
+type semaphore chan struct{}
+
+// acquire blocks until a slot is free and takes it, or until the context is done
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurst(t *testing.T) {
+	b := newTokenBucket(2, 3)
+	start := b.last
+
+	for i := 0; i < 3; i++ {
+		if delay := b.reserve(start); delay > 0 {
+			t.Fatalf("request %d of the burst waits %s", i+1, delay)
+		}
+	}
+	if delay := b.reserve(start); delay != 500*time.Millisecond {
+		t.Errorf("first request after the burst waits %s, want 500ms", delay)
+	}
+	if delay := b.reserve(start); delay != time.Second {
+		t.Errorf("second request after the burst waits %s, want 1s", delay)
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	b := newTokenBucket(2, 3)
+	start := b.last
+	for i := 0; i < 3; i++ {
+		b.reserve(start)
+	}
+
+	// A second refills two tokens
+	later := start.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		if delay := b.reserve(later); delay > 0 {
+			t.Fatalf("refilled request %d waits %s", i+1, delay)
+		}
+	}
+	if delay := b.reserve(later); delay != 500*time.Millisecond {
+		t.Errorf("request after the refill waits %s, want 500ms", delay)
+	}
+
+	// The bucket never holds more than the burst
+	muchLater := later.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if delay := b.reserve(muchLater); delay > 0 {
+			t.Fatalf("request %d of the burst waits %s", i+1, delay)
+		}
+	}
+	if delay := b.reserve(muchLater); delay != 500*time.Millisecond {
+		t.Errorf("request after a long pause and the burst waits %s, want 500ms", delay)
+	}
+}
+
+func TestTokenBucketWaitIsCancelled(t *testing.T) {
+	b := newTokenBucket(0.001, 1)
+	if err := b.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want the context to cancel the wait", err)
+	}
+
+	// The token reserved by the cancelled wait is returned to the bucket
+	if delay := b.reserve(b.last); delay > 1001*time.Second {
+		t.Errorf("request after a cancelled wait waits %s, want the wait for a single token", delay)
+	}
+}
+
+// instantTransport answers every request at once, or fails with err
+type instantTransport struct {
+	err error
+}
+
+func (t instantTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func newTestRequest(t *testing.T, ctx context.Context) *http.Request {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://balena.test/v7/application", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestLimitedTransportCapsRequestsInFlight(t *testing.T) {
+	transport := &limitedTransport{next: instantTransport{}, inFlight: make(semaphore, 2)}
+
+	var responses []*http.Response
+	for i := 0; i < 2; i++ {
+		res, err := transport.RoundTrip(newTestRequest(t, context.Background()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, res)
+	}
+	if len(transport.inFlight) != 2 {
+		t.Fatalf("%d requests in flight, want 2 until their bodies are closed", len(transport.inFlight))
+	}
+
+	// A third request waits for a slot until its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := transport.RoundTrip(newTestRequest(t, ctx)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the third request to wait for a slot", err)
+	}
+
+	// Closing a body frees its slot, once
+	for i := 0; i < 2; i++ {
+		if err := responses[0].Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(transport.inFlight) != 1 {
+		t.Fatalf("%d requests in flight after closing a body twice, want 1", len(transport.inFlight))
+	}
+	if _, err := transport.RoundTrip(newTestRequest(t, context.Background())); err != nil {
+		t.Fatalf("got %v, want the third request to take the free slot", err)
+	}
+}
+
+func TestLimitedTransportFreesTheSlotOfFailedRequests(t *testing.T) {
+	failure := errors.New("connection refused")
+	transport := &limitedTransport{next: instantTransport{err: failure}, inFlight: make(semaphore, 1)}
+
+	for i := 0; i < 2; i++ {
+		if _, err := transport.RoundTrip(newTestRequest(t, context.Background())); !errors.Is(err, failure) {
+			t.Fatalf("got %v, want %v", err, failure)
+		}
+	}
+	if len(transport.inFlight) != 0 {
+		t.Errorf("%d requests in flight after failures, want 0", len(transport.inFlight))
+	}
+}
+
+func TestLimitedTransportCancelsTheWaitForARateLimit(t *testing.T) {
+	transport := &limitedTransport{next: instantTransport{}, bucket: newTokenBucket(0.001, 1)}
+	if _, err := transport.RoundTrip(newTestRequest(t, context.Background())); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := transport.RoundTrip(newTestRequest(t, ctx)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want the context to cancel the wait for a token", err)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resource is a resource set of the Balena API, e.g. `application` for fleets
+type Resource string
+
+const (
+	Application                      Resource = "application"
+	ApplicationEnvironmentVariable   Resource = "application_environment_variable"
+	ApplicationConfigVariable        Resource = "application_config_variable"
+	ApplicationMembershipRole        Resource = "application_membership_role"
+	ApplicationTag                   Resource = "application_tag"
+	ApiKey                           Resource = "api_key"
+	CpuArchitecture                  Resource = "cpu_architecture"
+	Device                           Resource = "device"
+	DeviceConfigVariable             Resource = "device_config_variable"
+	DeviceEnvironmentVariable        Resource = "device_environment_variable"
+	DeviceServiceEnvironmentVariable Resource = "device_service_environment_variable"
+	DeviceTag                        Resource = "device_tag"
+	DeviceType                       Resource = "device_type"
+	Organization                     Resource = "organization"
+	OrganizationMembership           Resource = "organization_membership"
+	OrganizationMembershipRole       Resource = "organization_membership_role"
+	Release                          Resource = "release"
+	ReleaseTag                       Resource = "release_tag"
+	Service                          Resource = "service"
+	ServiceEnvironmentVariable       Resource = "service_environment_variable"
+	ServiceInstall                   Resource = "service_install"
+	Subscription                     Resource = "subscription"
+	Team                             Resource = "team"
+	TeamApplicationAccess            Resource = "team_application_access"
+	TeamMembership                   Resource = "team_membership"
+	User                             Resource = "user"
+	UserHasPublicKey                 Resource = "user__has__public_key"
+)
+
+// Query addresses a resource set, or a single object of it by key, together with the query options
+// `$filter`, `$select`, `$expand`, `$orderby` and `$top`. Without a resource, a query holds the
+// options of an `$expand`
+type Query struct {
+	resource Resource
+	key      string
+	filter   Filter
+	selects  []string
+	expands  []string
+	orderBy  []string
+	top      int
+}
+
+// All queries the whole resource set, usually narrowed down with Filter
+func (r Resource) All() *Query {
+	return &Query{resource: r}
+}
+
+// ID queries a single object by its numeric ID
+func (r Resource) ID(id int) *Query {
+	return &Query{resource: r, key: fmt.Sprintf("(%d)", id)}
+}
+
+// Key queries a single object by an alternate key, e.g. `device(uuid='...')`
+func (r Resource) Key(field string, value interface{}) *Query {
+	return &Query{resource: r, key: fmt.Sprintf("(%s=%s)", field, Literal(value))}
+}
+
+// Options starts the nested query options of an `$expand`
+func Options() *Query {
+	return &Query{}
+}
+
+// Filter narrows the query down, combining with previous filters
+func (q *Query) Filter(filter Filter) *Query {
+	q.filter = And(q.filter, filter)
+	return q
+}
+
+func (q *Query) Select(fields ...string) *Query {
+	q.selects = append(q.selects, fields...)
+	return q
+}
+
+// Expand includes the objects related through the navigation, with optional nested query options
+func (q *Query) Expand(navigation string, options *Query) *Query {
+	if options != nil {
+		if nested := options.options(";"); nested != "" {
+			navigation = fmt.Sprintf("%s(%s)", navigation, nested)
+		}
+	}
+	q.expands = append(q.expands, navigation)
+	return q
+}
+
+// OrderBy sorts the results, e.g. `OrderBy("created_at desc")`
+func (q *Query) OrderBy(orderings ...string) *Query {
+	q.orderBy = append(q.orderBy, orderings...)
+	return q
+}
+
+func (q *Query) Top(top int) *Query {
+	q.top = top
+	return q
+}
+
+// Path is the path of the resource set or object, without the query options
+func (q *Query) Path() string {
+	return fmt.Sprintf("/v7/%s%s", q.resource, q.key)
+}
+
+// String is the path together with the query options. Literals are escaped by the filter builder,
+// the remaining spaces of the expressions are encoded here
+func (q *Query) String() string {
+	options := q.options("&")
+	if options == "" {
+		return q.Path()
+	}
+	return q.Path() + "?" + strings.ReplaceAll(options, " ", "%20")
+}
+
+func (q *Query) options(separator string) string {
+	var options []string
+	if len(q.selects) > 0 {
+		options = append(options, "$select="+strings.Join(q.selects, ","))
+	}
+	if len(q.expands) > 0 {
+		options = append(options, "$expand="+strings.Join(q.expands, ","))
+	}
+	if q.filter != "" {
+		options = append(options, "$filter="+q.filter.String())
+	}
+	if len(q.orderBy) > 0 {
+		options = append(options, "$orderby="+strings.Join(q.orderBy, ","))
+	}
+	if q.top > 0 {
+		options = append(options, fmt.Sprintf("$top=%d", q.top))
+	}
+	return strings.Join(options, separator)
+}
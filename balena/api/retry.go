@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// retryBaseWait is the wait before the first retry, doubled on every further retry up to the max wait
+const retryBaseWait = 500 * time.Millisecond
+
+// idempotentMethods are the methods that are safe to repeat when their response is lost.
+// Pine PATCHes set fields to absolute values, so repeating one has the same effect as sending it once
+var idempotentMethods = map[string]bool{
+	resty.MethodGet:     true,
+	resty.MethodHead:    true,
+	resty.MethodOptions: true,
+	resty.MethodPut:     true,
+	resty.MethodPatch:   true,
+	resty.MethodDelete:  true,
+}
+
+// IsRetryable reports whether a request failed in a way worth retrying: Balena throttled it with 429,
+// failed with a 5xx or the connection failed before a response arrived
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if statusCode := StatusCode(err); statusCode != 0 {
+		return retryableStatusCode(statusCode)
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func retryableStatusCode(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// retryCondition decides for resty whether to retry a request, which it only does for idempotent ones
+func retryCondition(res *resty.Response, err error) bool {
+	if res == nil || res.Request == nil || !idempotentMethods[res.Request.Method] {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return retryableStatusCode(res.StatusCode())
+}
+
+// retryAfter tells resty to wait as long as Balena asks for in `Retry-After`, falling back to its
+// exponential backoff with jitter when the header is absent
+func retryAfter(_ *resty.Client, res *resty.Response) (time.Duration, error) {
+	return parseRetryAfter(res.Header().Get("Retry-After")), nil
+}
+
+// parseRetryAfter reads `Retry-After` as either a number of seconds or an HTTP date, returning 0 when it is absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// backoff is the wait before a retry: what Balena asked for in `Retry-After` if anything,
+// otherwise an exponentially growing wait with jitter, capped at the max wait either way
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, c.maxRetryWait)
+	}
+
+	wait := retryBaseWait
+	for i := 0; i < attempt && wait < c.maxRetryWait; i++ {
+		wait *= 2
+	}
+	wait = min(wait, c.maxRetryWait)
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// RetryCreate runs create, which posts a new object, retrying it on the same failures as idempotent requests.
+// As posting is not idempotent, a failed attempt may have created the object after all, so every retry
+// first checks with exists whether the object is there already
+func (c *Client) RetryCreate(create func() error, exists func() (bool, error)) error {
+	for attempt := 0; ; attempt++ {
+		err := create()
+		if !IsRetryable(err) || attempt >= c.maxRetries {
+			return err
+		}
+
+		time.Sleep(c.backoff(attempt, err))
+
+		found, existsErr := exists()
+		if existsErr != nil {
+			return existsErr
+		}
+		if found {
+			return nil
+		}
+	}
+}
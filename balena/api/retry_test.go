@@ -0,0 +1,267 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testMaxRetryWait = 20 * time.Millisecond
+
+// testServer answers every request with the handler, counting the requests per method
+func testServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, attempt int)) (*Client, map[string]*int32) {
+	counts := map[string]*int32{http.MethodGet: new(int32), http.MethodPost: new(int32), http.MethodPatch: new(int32)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, int(atomic.AddInt32(counts[r.Method], 1)))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, "token", Settings{MaxRetries: 3, MaxRetryWait: testMaxRetryWait})
+	return client, counts
+}
+
+// dropConnection closes the connection without a response, as a network failure would
+func dropConnection(t *testing.T, w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("7"); got != 7*time.Second {
+		t.Errorf("seconds: got %s, want 7s", got)
+	}
+
+	date := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 80*time.Second || got > 90*time.Second {
+		t.Errorf("HTTP date: got %s, want about 90s", got)
+	}
+
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	for _, value := range []string{"", "0", "-3", "soon", past} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Errorf("%q: got %s, want 0", value, got)
+		}
+	}
+}
+
+func TestBackoffStaysUnderMaxRetryWait(t *testing.T) {
+	c := &Client{maxRetryWait: 2 * time.Second}
+
+	for attempt := 0; attempt < 20; attempt++ {
+		for i := 0; i < 50; i++ {
+			if wait := c.backoff(attempt, errors.New("failed")); wait <= 0 || wait > c.maxRetryWait {
+				t.Fatalf("attempt %d: backoff %s outside of (0, %s]", attempt, wait, c.maxRetryWait)
+			}
+		}
+	}
+
+	throttled := &Error{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}
+	if wait := c.backoff(0, throttled); wait != time.Second {
+		t.Errorf("Retry-After under the max wait: got %s, want 1s", wait)
+	}
+	throttled.RetryAfter = time.Hour
+	if wait := c.backoff(0, throttled); wait != c.maxRetryWait {
+		t.Errorf("Retry-After over the max wait: got %s, want %s", wait, c.maxRetryWait)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&Error{StatusCode: http.StatusTooManyRequests}, true},
+		{&Error{StatusCode: http.StatusServiceUnavailable}, true},
+		{&Error{StatusCode: http.StatusBadRequest}, false},
+		{&Error{StatusCode: http.StatusNotFound}, false},
+		{&Error{StatusCode: http.StatusConflict}, false},
+		{errors.New("not an API error"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGetRetriesThrottledAndFailedRequests(t *testing.T) {
+	client, counts := testServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		switch attempt {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		case 3:
+			dropConnection(t, w)
+		default:
+			_, _ = w.Write([]byte(`{"d":[{"id":1}]}`))
+		}
+	})
+
+	start := time.Now()
+	objects, err := Get[createdTestObject](client, Application.All())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Id != 1 {
+		t.Errorf("got %v", objects)
+	}
+	if got := atomic.LoadInt32(counts[http.MethodGet]); got != 4 {
+		t.Errorf("got %d requests, want 4", got)
+	}
+	// The Retry-After of a second is capped at the max wait
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retries took %s, longer than the max wait allows", elapsed)
+	}
+}
+
+func TestGetDoesNotRetryClientErrors(t *testing.T) {
+	for _, statusCode := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict} {
+		client, counts := testServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+			w.WriteHeader(statusCode)
+		})
+
+		_, err := Get[createdTestObject](client, Application.All())
+		if StatusCode(err) != statusCode {
+			t.Errorf("%d: got error %v", statusCode, err)
+		}
+		if got := atomic.LoadInt32(counts[http.MethodGet]); got != 1 {
+			t.Errorf("%d: got %d requests, want 1", statusCode, got)
+		}
+	}
+}
+
+func TestGetGivesUpAfterMaxRetries(t *testing.T) {
+	client, counts := testServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err := Get[createdTestObject](client, Application.All())
+	if StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("got error %v", err)
+	}
+	if got := atomic.LoadInt32(counts[http.MethodGet]); got != 4 {
+		t.Errorf("got %d requests, want the request and 3 retries", got)
+	}
+}
+
+func TestPatchIsRetried(t *testing.T) {
+	client, counts := testServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if attempt == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	if err := client.Patch(Application.ID(1), map[string]interface{}{"app_name": "fleet"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(counts[http.MethodPatch]); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestPostIsNotRetriedByItself(t *testing.T) {
+	client, counts := testServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	if _, err := Create[createdTestObject](client, Application, map[string]interface{}{}); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("got error %v", err)
+	}
+	if got := atomic.LoadInt32(counts[http.MethodPost]); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+type createdTestObject struct {
+	Id int `json:"id"`
+}
+
+func TestRetryCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail fails the first post, after which the object exists when created is true
+		fail      func(t *testing.T, w http.ResponseWriter)
+		created   bool
+		wantPosts int32
+		wantErr   int
+	}{
+		{
+			name:      "5xx after creating the object",
+			fail:      func(t *testing.T, w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+			created:   true,
+			wantPosts: 1,
+		},
+		{
+			name:      "connection lost after creating the object",
+			fail:      dropConnection,
+			created:   true,
+			wantPosts: 1,
+		},
+		{
+			name:      "5xx before creating the object",
+			fail:      func(t *testing.T, w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			wantPosts: 2,
+		},
+		{
+			name:      "throttled",
+			fail:      func(t *testing.T, w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) },
+			wantPosts: 2,
+		},
+		{
+			name:      "client error",
+			fail:      func(t *testing.T, w http.ResponseWriter) { w.WriteHeader(http.StatusConflict) },
+			wantPosts: 1,
+			wantErr:   http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exists atomic.Bool
+			client, counts := testServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+				switch {
+				case r.Method == http.MethodGet && exists.Load():
+					_, _ = w.Write([]byte(`{"d":[{"id":1}]}`))
+				case r.Method == http.MethodGet:
+					_, _ = w.Write([]byte(`{"d":[]}`))
+				case attempt == 1:
+					exists.Store(tt.created)
+					tt.fail(t, w)
+				default:
+					exists.Store(true)
+					_, _ = w.Write([]byte(`{"id":1}`))
+				}
+			})
+
+			checks := 0
+			err := client.RetryCreate(func() error {
+				_, err := Create[createdTestObject](client, Application, map[string]interface{}{"app_name": "fleet"})
+				return err
+			}, func() (bool, error) {
+				checks++
+				objects, err := Get[createdTestObject](client, Application.All().Filter(Eq("app_name", "fleet")))
+				return len(objects) > 0, err
+			})
+
+			if StatusCode(err) != tt.wantErr || (tt.wantErr == 0 && err != nil) {
+				t.Fatalf("got error %v, want status code %d", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(counts[http.MethodPost]); got != tt.wantPosts {
+				t.Errorf("got %d posts, want %d", got, tt.wantPosts)
+			}
+			if tt.wantErr == 0 && checks != 1 {
+				t.Errorf("checked whether the object exists %d times, want once before the retry", checks)
+			}
+		})
+	}
+}
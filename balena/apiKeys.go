@@ -0,0 +1,339 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"time"
+)
+
+// ApiKey is the format API keys, including provisioning keys, are returned from the Balena API.
+// The key itself is only returned once, when it is generated
+type ApiKey struct {
+	Id          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ExpiryDate  string    `json:"expiry_date"`
+	Created     string    `json:"created_at"`
+	Actor       IDWrapper `json:"is_of__actor"`
+}
+
+// apiKeyFields are the fields of an ApiKey
+var apiKeyFields = []string{"id", "name", "description", "expiry_date", "created_at", "is_of__actor"}
+
+// WhoAmI is the actor the credentials of the provider belong to
+type WhoAmI struct {
+	ActorId  int    `json:"id"`
+	Username string `json:"username"`
+}
+
+func GetApiKeyId(apiKeyId int) string {
+	return fmt.Sprintf("api-key:%d", apiKeyId)
+}
+
+// suppressEquivalentExpiryDate ignores the difference between an expiry date in the configuration and
+// the same time as Balena formats it, e.g. in UTC with milliseconds
+func suppressEquivalentExpiryDate(_, old, new string, _ *schema.ResourceData) bool {
+	oldTime, err := time.Parse(time.RFC3339, old)
+	if err != nil {
+		return false
+	}
+	newTime, err := time.Parse(time.RFC3339, new)
+	if err != nil {
+		return false
+	}
+	return oldTime.Equal(newTime)
+}
+
+// getApiKeyAttributesSchema the computed attributes of an API key, shared by the elements of the API key data sources
+func getApiKeyAttributesSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"key_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the key.",
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The name of the key.",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The description of the key.",
+		},
+		"expiry_date": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The time the key expires, represented as a string in ISO-Format, or empty when it never expires.",
+		},
+		"created": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The time the key was created, represented as a string in ISO-Format.",
+		},
+	}
+}
+
+func dataSourceApiKeys() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetApiKeysDataSource,
+		Description: "List the named API keys of the user the provider is authenticated as, without the keys themselves.",
+		Schema: map[string]*schema.Schema{
+			"api_keys": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The API keys of the user, newest first.",
+				Elem: &schema.Resource{
+					Schema: getApiKeyAttributesSchema(),
+				},
+			},
+		},
+	}
+}
+
+func resourceApiKey() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceApiKeyCreate,
+		ReadContext:   ResourceApiKeyRead,
+		UpdateContext: ResourceApiKeyUpdate,
+		DeleteContext: ResourceApiKeyDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceApiKeyImport,
+		},
+		Description: "Generate an API key for the user the provider is authenticated as, e.g. for CI or monitoring. " +
+			"The key is only available when it is generated, so it is empty after an import. Destroying the resource revokes the key. " +
+			"Organization API keys are not supported.",
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the key, which has to be unique among the keys of the user.",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The description of the key.",
+			},
+			"expiry_date": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateFunc:     validation.IsRFC3339Time,
+				DiffSuppressFunc: suppressEquivalentExpiryDate,
+				Description:      "The time the key expires, as an RFC 3339 timestamp. Changing this generates a new key.",
+			},
+			"key": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The API key.",
+			},
+			"key_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the key.",
+			},
+			"created": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the key was created, represented as a string in ISO-Format.",
+			},
+		},
+	}
+}
+
+// FetchWhoAmI retrieves the actor the credentials of the provider belong to
+func FetchWhoAmI(client *api.Client) (*WhoAmI, diag.Diagnostics) {
+	var whoAmI WhoAmI
+	if err := client.GetJSON("/actor/v1/whoami", &whoAmI); err != nil {
+		return nil, diag.Errorf("error retrieving the authenticated actor: %s", err)
+	}
+	return &whoAmI, nil
+}
+
+// DescribeApiKeys lists the API keys of an actor, e.g. of a fleet for provisioning keys, matching an additional OData filter
+func DescribeApiKeys(client *api.Client, actorId int, filter api.Filter) ([]ApiKey, diag.Diagnostics) {
+	apiKeys, err := api.Get[ApiKey](client, api.ApiKey.All().
+		Select(apiKeyFields...).
+		Filter(api.And(api.Eq("is_of__actor", actorId), filter)).
+		OrderBy("id desc"))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving API Keys: %s", err)
+	}
+	return apiKeys, nil
+}
+
+// LookupApiKey retrieves an API key by ID, returning nil without an error when it was revoked
+func LookupApiKey(client *api.Client, apiKeyId int) (*ApiKey, diag.Diagnostics) {
+	apiKeys, err := api.Get[ApiKey](client, api.ApiKey.All().
+		Select(apiKeyFields...).
+		Filter(api.Eq("id", apiKeyId)))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving API Key: %s", err)
+	}
+
+	if len(apiKeys) == 0 {
+		return nil, nil
+	}
+	return &apiKeys[0], nil
+}
+
+// GenerateApiKey generates a key through one of the `/api-key/...` endpoints, which return the key
+// rather than the created object, and finds the ID of the new key among the keys of the actor by its name.
+// The name therefore has to be unique among the keys of the actor
+func GenerateApiKey(client *api.Client, endpoint string, actorId int, name string, description string, expiryDate string) (string, int, diag.Diagnostics) {
+	existing, diags := DescribeApiKeys(client, actorId, api.Eq("name", name))
+	if diags.HasError() {
+		return "", 0, diags
+	}
+	if len(existing) > 0 {
+		return "", 0, diag.Errorf("an api key named %s already exists, api keys generated by Terraform need a unique name", name)
+	}
+
+	body := map[string]interface{}{
+		"name":        name,
+		"description": description,
+	}
+	if expiryDate != "" {
+		body["expiryDate"] = expiryDate
+	}
+
+	var key string
+	if err := client.PostJSON(endpoint, body, &key); err != nil {
+		return "", 0, diag.Errorf("error creating api key %s: %s", name, err)
+	}
+
+	apiKeys, diags := DescribeApiKeys(client, actorId, api.Eq("name", name))
+	if diags.HasError() {
+		return "", 0, diags
+	}
+	if len(apiKeys) == 0 {
+		return "", 0, diag.Errorf("the api key %s was generated but could not be found", name)
+	} else if len(apiKeys) > 1 {
+		return "", 0, diag.Errorf("the api key %s was generated, but another key with the same name was created concurrently", name)
+	}
+
+	return key, apiKeys[0].Id, nil
+}
+
+// flattenApiKey converts an API key to the attributes of `getApiKeyAttributesSchema`
+func flattenApiKey(apiKey ApiKey) map[string]interface{} {
+	return map[string]interface{}{
+		"key_id":      apiKey.Id,
+		"name":        apiKey.Name,
+		"description": apiKey.Description,
+		"expiry_date": apiKey.ExpiryDate,
+		"created":     apiKey.Created,
+	}
+}
+
+func GetApiKeysDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	whoAmI, err := FetchWhoAmI(client)
+	if err != nil {
+		return err
+	}
+
+	// Unnamed keys are the session tokens of the user rather than API keys
+	apiKeys, err := DescribeApiKeys(client, whoAmI.ActorId, api.Ne("name", nil))
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, apiKey := range apiKeys {
+		response = append(response, flattenApiKey(apiKey))
+	}
+	if err := d.Set("api_keys", response); err != nil {
+		return diag.Errorf("failed to set api_keys: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("api-keys:%d", whoAmI.ActorId))
+	return nil
+}
+
+func ResourceApiKeyCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	whoAmI, err := FetchWhoAmI(client)
+	if err != nil {
+		return err
+	}
+
+	key, apiKeyId, err := GenerateApiKey(client,
+		"/api-key/user/full",
+		whoAmI.ActorId,
+		d.Get("name").(string),
+		d.Get("description").(string),
+		d.Get("expiry_date").(string),
+	)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetApiKeyId(apiKeyId))
+	_ = d.Set("key", key)
+	return ResourceApiKeyRead(ctx, d, m)
+}
+
+func ResourceApiKeyRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	apiKeyId, parseErr := ParseNumericId("api-key", d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	apiKey, err := LookupApiKey(client, apiKeyId)
+	if err != nil {
+		return err
+	}
+	if apiKey == nil {
+		return removeFromState(d, "api key %d no longer exists", apiKeyId)
+	}
+
+	_ = d.Set("key_id", apiKey.Id)
+	_ = d.Set("name", apiKey.Name)
+	_ = d.Set("description", apiKey.Description)
+	_ = d.Set("expiry_date", apiKey.ExpiryDate)
+	_ = d.Set("created", apiKey.Created)
+	return nil
+}
+
+func ResourceApiKeyUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	if d.HasChanges("name", "description") {
+		if err := UpdateObject(client, api.ApiKey, d.Get("key_id").(int), map[string]interface{}{
+			"name":        d.Get("name").(string),
+			"description": d.Get("description").(string),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return ResourceApiKeyRead(ctx, d, m)
+}
+
+func ResourceApiKeyDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return DeleteObject(client, api.ApiKey, d.Get("key_id").(int))
+}
+
+// ResourceApiKeyImport accepts the resource ID (`api-key:<key_id>`) as well as a bare key ID
+func ResourceApiKeyImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	apiKeyId, err := ParseNumericId("api-key", d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(GetApiKeyId(apiKeyId))
+	return []*schema.ResourceData{d}, nil
+}
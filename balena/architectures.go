@@ -1 +1,166 @@
 package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+)
+
+// CpuArchitecture is the format CPU architectures are returned from the Balena API,
+// both on their own and when expanded from a device type
+type CpuArchitecture struct {
+	Id          int                         `json:"id"`
+	Slug        string                      `json:"slug"`
+	DeviceTypes []CpuArchitectureDeviceType `json:"is_supported_by__device_type"`
+}
+
+type CpuArchitectureDeviceType struct {
+	Id   int    `json:"id"`
+	Slug string `json:"slug"`
+}
+
+func GetCpuArchitectureId(cpuArchitectureId int) string {
+	return fmt.Sprintf("cpu-architecture:%d", cpuArchitectureId)
+}
+
+// getCpuArchitectureAttributesSchema the computed attributes of an architecture, shared by
+// `balena_cpu_architecture` and the elements of `balena_cpu_architectures`
+func getCpuArchitectureAttributesSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"cpu_architecture_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the CPU architecture.",
+		},
+		"slug": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The slug of the CPU architecture, e.g. `aarch64`, `armv7hf` or `amd64`.",
+		},
+		"device_types": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The slugs of the device types of this CPU architecture.",
+		},
+	}
+}
+
+func getCpuArchitectureDataSourceSchema() map[string]*schema.Schema {
+	s := getCpuArchitectureAttributesSchema()
+	s["cpu_architecture_id"] = &schema.Schema{
+		Type:         schema.TypeInt,
+		Optional:     true,
+		Computed:     true,
+		ExactlyOneOf: []string{"cpu_architecture_id", "slug"},
+		Description:  "The ID of the CPU architecture. Exactly one of `cpu_architecture_id` and `slug` must be set.",
+	}
+	s["slug"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Computed:     true,
+		ExactlyOneOf: []string{"cpu_architecture_id", "slug"},
+		Description:  "The slug of the CPU architecture, e.g. `aarch64`, `armv7hf` or `amd64`.",
+	}
+	return s
+}
+
+func dataSourceCpuArchitecture() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetCpuArchitectureDataSource,
+		Schema:      getCpuArchitectureDataSourceSchema(),
+		Description: "Retrieve a CPU architecture and its device types given its `cpu_architecture_id` or `slug`.",
+	}
+}
+
+func dataSourceCpuArchitectures() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetCpuArchitecturesDataSource,
+		Description: "List the CPU architectures supported by Balena.",
+		Schema: map[string]*schema.Schema{
+			"cpu_architectures": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: getCpuArchitectureAttributesSchema(),
+				},
+			},
+		},
+	}
+}
+
+// DescribeCpuArchitectures lists CPU architectures with their device types, matching an OData filter which may be empty
+func DescribeCpuArchitectures(client *api.Client, filter api.Filter) ([]CpuArchitecture, diag.Diagnostics) {
+	cpuArchitectures, err := api.Get[CpuArchitecture](client, api.CpuArchitecture.All().
+		Expand("is_supported_by__device_type", api.Options().Select("id", "slug").OrderBy("slug asc")).
+		Filter(filter).
+		OrderBy("slug asc"))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving CPU Architectures: %s", err)
+	}
+	return cpuArchitectures, nil
+}
+
+// flattenCpuArchitecture converts an architecture to the attributes of `getCpuArchitectureAttributesSchema`
+func flattenCpuArchitecture(cpuArchitecture CpuArchitecture) map[string]interface{} {
+	deviceTypes := make([]string, 0)
+	for _, deviceType := range cpuArchitecture.DeviceTypes {
+		deviceTypes = append(deviceTypes, deviceType.Slug)
+	}
+
+	return map[string]interface{}{
+		"cpu_architecture_id": cpuArchitecture.Id,
+		"slug":                cpuArchitecture.Slug,
+		"device_types":        deviceTypes,
+	}
+}
+
+func GetCpuArchitectureDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	var filter api.Filter
+	if slug := d.Get("slug").(string); slug != "" {
+		filter = api.Eq("slug", slug)
+	} else {
+		filter = api.Eq("id", d.Get("cpu_architecture_id").(int))
+	}
+
+	cpuArchitectures, err := DescribeCpuArchitectures(client, filter)
+	if err != nil {
+		return err
+	}
+	if len(cpuArchitectures) == 0 {
+		return diag.Errorf("no cpu architecture found")
+	}
+
+	for dataSourceAttribute, value := range flattenCpuArchitecture(cpuArchitectures[0]) {
+		if err := d.Set(dataSourceAttribute, value); err != nil {
+			return diag.Errorf("failed to set %s: %v", dataSourceAttribute, err)
+		}
+	}
+
+	d.SetId(GetCpuArchitectureId(cpuArchitectures[0].Id))
+	return nil
+}
+
+func GetCpuArchitecturesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	cpuArchitectures, err := DescribeCpuArchitectures(client, "")
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, cpuArchitecture := range cpuArchitectures {
+		response = append(response, flattenCpuArchitecture(cpuArchitecture))
+	}
+	if err := d.Set("cpu_architectures", response); err != nil {
+		return diag.Errorf("failed to set cpu_architectures: %v", err)
+	}
+
+	d.SetId("cpu-architectures")
+	return nil
+}
@@ -0,0 +1,482 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strings"
+)
+
+// configVariablePrefixes are the prefixes reserved by Balena for configuration variables,
+// which configure the supervisor and host OS rather than the containers of a release
+var configVariablePrefixes = []string{"BALENA_", "RESIN_"}
+
+// ConfigVariable is the format that both fleet and device configuration variables
+// are returned from the Balena API
+type ConfigVariable struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Created string `json:"created_at"`
+}
+
+func isConfigVariableName(name string) bool {
+	for _, prefix := range configVariablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConfigVariableName ensures a configuration variable carries a reserved prefix
+func validateConfigVariableName(v interface{}, k string) (ws []string, errors []error) {
+	if !isConfigVariableName(v.(string)) {
+		errors = append(errors, fmt.Errorf("`%s` must start with one of %s", k, strings.Join(configVariablePrefixes, ", ")))
+	}
+	return
+}
+
+// validateEnvironmentVariableName ensures an environment variable does not use a prefix
+// reserved for configuration variables
+func validateEnvironmentVariableName(v interface{}, k string) (ws []string, errors []error) {
+	if isConfigVariableName(v.(string)) {
+		errors = append(errors, fmt.Errorf("`%s` must not start with one of %s, use a configuration variable resource instead", k, strings.Join(configVariablePrefixes, ", ")))
+	}
+	return
+}
+
+func GetSingularFleetConfigVariableId(fleetId int, variableName string) string {
+	return fmt.Sprintf("fleet-config-variable:%d:%s", fleetId, variableName)
+}
+
+func GetPluralFleetConfigVariableId(fleetId int) string {
+	return fmt.Sprintf("fleet-config-variable:%d", fleetId)
+}
+
+func GetSingularDeviceConfigVariableId(deviceUuid string, variableName string) string {
+	return fmt.Sprintf("device-config-variable:%s:%s", deviceUuid, variableName)
+}
+
+func GetPluralDeviceConfigVariableId(deviceUuid string) string {
+	return fmt.Sprintf("device-config-variable:%s", deviceUuid)
+}
+
+func dataSourceFleetConfigVariables() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetFleetConfigVariablesDataSource,
+		Description: "Get the configuration variables (`BALENA_*` and `RESIN_*`) of a fleet.",
+		Schema: map[string]*schema.Schema{
+			"fleet_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+			},
+			"variables": {
+				Type:     schema.TypeMap,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceDeviceConfigVariables() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceConfigVariablesDataSource,
+		Description: "Get the configuration variables (`BALENA_*` and `RESIN_*`) configured directly on a device.",
+		Schema: map[string]*schema.Schema{
+			"device_uuid": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"variables": {
+				Type:     schema.TypeMap,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceFleetConfigVariable() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceFleetConfigVariableCreate,
+		UpdateContext: ResourceFleetConfigVariableUpdate,
+		ReadContext:   ResourceFleetConfigVariableRead,
+		DeleteContext: ResourceFleetConfigVariableDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceFleetConfigVariableImport,
+		},
+		Description: "Manage a configuration variable of a fleet, such as `BALENA_SUPERVISOR_POLL_INTERVAL` or `BALENA_HOST_CONFIG_gpu_mem`.",
+		Schema: map[string]*schema.Schema{
+			"fleet_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"variable_name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateConfigVariableName,
+			},
+			"value": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+		},
+	}
+}
+
+func resourceDeviceConfigVariable() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceDeviceConfigVariableCreate,
+		UpdateContext: ResourceDeviceConfigVariableUpdate,
+		ReadContext:   ResourceDeviceConfigVariableRead,
+		DeleteContext: ResourceDeviceConfigVariableDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceDeviceConfigVariableImport,
+		},
+		Description: "Manage a configuration variable of a single device. It overrides the fleet configuration variable with the same name.",
+		Schema: map[string]*schema.Schema{
+			"device_uuid": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"variable_name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateConfigVariableName,
+			},
+			"value": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+		},
+	}
+}
+
+// describeConfigVariables retrieves configuration variables of either a fleet or a device
+func describeConfigVariables(client *api.Client, query *api.Query) ([]ConfigVariable, diag.Diagnostics) {
+	configVariables, err := api.Get[ConfigVariable](client, query)
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Config Variables: %s", err)
+	}
+	return configVariables, nil
+}
+
+func DescribeFleetConfigVariables(client *api.Client, fleetId int) ([]ConfigVariable, diag.Diagnostics) {
+	return describeConfigVariables(client, api.ApplicationConfigVariable.All().Filter(api.Eq("application", fleetId)))
+}
+
+func DescribeDeviceConfigVariables(client *api.Client, deviceUuid string) ([]ConfigVariable, diag.Diagnostics) {
+	return describeConfigVariables(client, api.DeviceConfigVariable.All().Filter(api.Eq("device/uuid", deviceUuid)))
+}
+
+// LookupFleetConfigVariable finds a single configuration variable of a fleet, returning nil without an error
+// when the variable does not exist
+func LookupFleetConfigVariable(client *api.Client, fleetId int, variableName string) (*ConfigVariable, diag.Diagnostics) {
+	return lookupObject(func() ([]ConfigVariable, diag.Diagnostics) {
+		return DescribeFleetConfigVariables(client, fleetId)
+	}, func(variable ConfigVariable) bool {
+		return variable.Name == variableName
+	})
+}
+
+// LookupDeviceConfigVariable finds a single configuration variable of a device, returning nil without an error
+// when the variable does not exist
+func LookupDeviceConfigVariable(client *api.Client, deviceUuid string, variableName string) (*ConfigVariable, diag.Diagnostics) {
+	return lookupObject(func() ([]ConfigVariable, diag.Diagnostics) {
+		return DescribeDeviceConfigVariables(client, deviceUuid)
+	}, func(variable ConfigVariable) bool {
+		return variable.Name == variableName
+	})
+}
+
+func GetFleetConfigVariablesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	variables, err := DescribeFleetConfigVariables(client, fleetId)
+	if err != nil {
+		return err
+	}
+
+	variableMap := make(map[string]string)
+	for _, variable := range variables {
+		variableMap[variable.Name] = variable.Value
+	}
+	_ = d.Set("fleet_id", fleetId)
+	_ = d.Set("variables", variableMap)
+
+	d.SetId(GetPluralFleetConfigVariableId(fleetId))
+	return nil
+}
+
+func GetDeviceConfigVariablesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variables, err := DescribeDeviceConfigVariables(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	variableMap := make(map[string]string)
+	for _, variable := range variables {
+		variableMap[variable.Name] = variable.Value
+	}
+	_ = d.Set("device_uuid", deviceUuid)
+	_ = d.Set("variables", variableMap)
+
+	d.SetId(GetPluralDeviceConfigVariableId(deviceUuid))
+	return nil
+}
+
+func ResourceFleetConfigVariableCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	variableName := d.Get("variable_name").(string)
+
+	existing, err := LookupFleetConfigVariable(client, fleetId, variableName)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return diag.Errorf("config variable %s already exists", variableName)
+	}
+
+	err = CreateConfigVariable(client, api.ApplicationConfigVariable, map[string]interface{}{
+		"application": fleetId,
+		"name":        variableName,
+		"value":       d.Get("value").(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetSingularFleetConfigVariableId(fleetId, variableName))
+	return nil
+}
+
+// ResourceFleetConfigVariableRead removes a variable deleted outside of Terraform from state
+func ResourceFleetConfigVariableRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupFleetConfigVariable(client, fleetId, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return removeFromState(d, "config variable %s no longer exists for the fleet %d", variableName, fleetId)
+	}
+
+	_ = d.Set("fleet_id", fleetId)
+	_ = d.Set("variable_name", variable.Name)
+	_ = d.Set("value", variable.Value)
+	return nil
+}
+
+func ResourceFleetConfigVariableUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupFleetConfigVariable(client, fleetId, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return diag.Errorf("no config variable %s configured for the fleet %d", variableName, fleetId)
+	}
+
+	return UpdateConfigVariable(client, api.ApplicationConfigVariable, variable.Id, d.Get("value").(string))
+}
+
+func ResourceFleetConfigVariableDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	variable, err := LookupFleetConfigVariable(client, d.Get("fleet_id").(int), d.Get("variable_name").(string))
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		// Already deleted outside of Terraform
+		return nil
+	}
+
+	return DeleteConfigVariable(client, api.ApplicationConfigVariable, variable.Id)
+}
+
+// ResourceFleetConfigVariableImport accepts the resource ID (`fleet-config-variable:<fleet_id>:<variable_name>`),
+// as well as `<fleet_id>/<variable_name>` and `<fleet slug>/<variable_name>`
+func ResourceFleetConfigVariableImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	var fleetReference, variableName string
+	if strings.HasPrefix(d.Id(), "fleet-config-variable:") {
+		parts := strings.SplitN(strings.TrimPrefix(d.Id(), "fleet-config-variable:"), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid import ID %q, expected fleet-config-variable:<fleet_id>:<variable_name>", d.Id())
+		}
+		fleetReference, variableName = parts[0], parts[1]
+	} else {
+		separator := strings.LastIndex(d.Id(), "/")
+		if separator == -1 {
+			return nil, fmt.Errorf("invalid import ID %q, expected <fleet_id or slug>/<variable_name>", d.Id())
+		}
+		fleetReference, variableName = d.Id()[:separator], d.Id()[separator+1:]
+	}
+
+	fleetId, err := resolveFleetImportId(client, fleetReference)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = d.Set("fleet_id", fleetId)
+	_ = d.Set("variable_name", variableName)
+	d.SetId(GetSingularFleetConfigVariableId(fleetId, variableName))
+	return []*schema.ResourceData{d}, nil
+}
+
+func ResourceDeviceConfigVariableCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+
+	device, err := FetchDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	existing, err := LookupDeviceConfigVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return diag.Errorf("config variable %s already exists", variableName)
+	}
+
+	err = CreateConfigVariable(client, api.DeviceConfigVariable, map[string]interface{}{
+		"device": device.Id,
+		"name":   variableName,
+		"value":  d.Get("value").(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetSingularDeviceConfigVariableId(deviceUuid, variableName))
+	return nil
+}
+
+// ResourceDeviceConfigVariableRead removes a variable deleted outside of Terraform from state
+func ResourceDeviceConfigVariableRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupDeviceConfigVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return removeFromState(d, "config variable %s no longer exists for the device %s", variableName, deviceUuid)
+	}
+
+	_ = d.Set("device_uuid", deviceUuid)
+	_ = d.Set("variable_name", variable.Name)
+	_ = d.Set("value", variable.Value)
+	return nil
+}
+
+func ResourceDeviceConfigVariableUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupDeviceConfigVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return diag.Errorf("no config variable %s configured for the device %s", variableName, deviceUuid)
+	}
+
+	return UpdateConfigVariable(client, api.DeviceConfigVariable, variable.Id, d.Get("value").(string))
+}
+
+func ResourceDeviceConfigVariableDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	variable, err := LookupDeviceConfigVariable(client, d.Get("device_uuid").(string), d.Get("variable_name").(string))
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		// Already deleted outside of Terraform
+		return nil
+	}
+
+	return DeleteConfigVariable(client, api.DeviceConfigVariable, variable.Id)
+}
+
+// ResourceDeviceConfigVariableImport accepts the resource ID (`device-config-variable:<device_uuid>:<variable_name>`)
+// as well as `<device_uuid>/<variable_name>`
+func ResourceDeviceConfigVariableImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	var parts []string
+	if strings.HasPrefix(d.Id(), "device-config-variable:") {
+		parts = strings.SplitN(strings.TrimPrefix(d.Id(), "device-config-variable:"), ":", 2)
+	} else {
+		parts = strings.SplitN(d.Id(), "/", 2)
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid import ID %q, expected <device_uuid>/<variable_name>", d.Id())
+	}
+
+	device, diags := FetchDevice(client, parts[0])
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+
+	_ = d.Set("device_uuid", device.Uuid)
+	_ = d.Set("variable_name", parts[1])
+	d.SetId(GetSingularDeviceConfigVariableId(device.Uuid, parts[1]))
+	return []*schema.ResourceData{d}, nil
+}
+
+// CreateConfigVariable creates a variable in either `application_config_variable` or `device_config_variable`
+func CreateConfigVariable(client *api.Client, resource api.Resource, body map[string]interface{}) diag.Diagnostics {
+	if _, err := api.Create[ConfigVariable](client, resource, body); err != nil {
+		return diag.Errorf("error creating config variable: %s", err)
+	}
+	return nil
+}
+
+func UpdateConfigVariable(client *api.Client, resource api.Resource, configVariableId int, variableValue string) diag.Diagnostics {
+	if err := client.Patch(resource.ID(configVariableId), map[string]interface{}{
+		"value": variableValue,
+	}); err != nil {
+		return diag.Errorf("error updating config variable: %s", err)
+	}
+	return nil
+}
+
+func DeleteConfigVariable(client *api.Client, resource api.Resource, configVariableId int) diag.Diagnostics {
+	if err := client.Delete(resource.ID(configVariableId)); err != nil {
+		return diag.Errorf("error deleting config variable: %s", err)
+	}
+	return nil
+}
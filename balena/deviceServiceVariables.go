@@ -0,0 +1,445 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strconv"
+	"strings"
+)
+
+// DeviceServiceVariable is the format that device service variables are returned
+// from the Balena API when expanding the service install they belong to
+type DeviceServiceVariable struct {
+	Id             int              `json:"id"`
+	Name           string           `json:"name"`
+	Value          string           `json:"value"`
+	Created        string           `json:"created_at"`
+	ServiceInstall []ServiceInstall `json:"service_install"`
+}
+
+// ServiceId returns the ID of the service the variable overrides
+func (v DeviceServiceVariable) ServiceId() int {
+	if len(v.ServiceInstall) == 0 {
+		return 0
+	}
+	return v.ServiceInstall[0].Service.ID
+}
+
+// ServiceInstall links a device to a service of its fleet -- device service variables
+// are attached to it rather than to the device or the service
+type ServiceInstall struct {
+	Id      int       `json:"id"`
+	Service IDWrapper `json:"installs__service"`
+}
+
+func GetSingularDeviceServiceVariableId(deviceUuid string, serviceId int, variableName string) string {
+	return fmt.Sprintf("device-service-variable:%s:%d:%s", deviceUuid, serviceId, variableName)
+}
+
+func GetPluralDeviceServiceVariableId(deviceUuid string, serviceId int) string {
+	return fmt.Sprintf("device-service-variable:%s:%d", deviceUuid, serviceId)
+}
+
+func dataSourceDeviceServiceVariable() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceServiceVariableDataSource,
+		Schema:      getDeviceServiceVariableDataSourceSchema(false),
+	}
+}
+
+func dataSourceDeviceServiceVariableSensitive() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceServiceVariableDataSource,
+		Schema:      getDeviceServiceVariableDataSourceSchema(true),
+	}
+}
+
+func dataSourceDeviceServiceVariables() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceServiceVariablesDataSource,
+		Schema:      getDeviceServiceVariablesDataSourceSchema(),
+		Description: "Get the variables overriding a single service on a single device.",
+	}
+}
+
+// getDeviceServiceKeySchema the attributes identifying the service of a device, shared by
+// the data sources and the resource
+func getDeviceServiceKeySchema(forceNew bool) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"device_uuid": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    forceNew,
+			Description: "The UUID of the device.",
+		},
+		"service_id": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Computed:     true,
+			ForceNew:     forceNew,
+			ExactlyOneOf: []string{"service_id", "service_name"},
+			Description:  "The ID of the service. Exactly one of `service_id` and `service_name` must be set.",
+		},
+		"service_name": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			ForceNew:     forceNew,
+			ExactlyOneOf: []string{"service_id", "service_name"},
+			Description:  "The name of the service in the fleet of the device.",
+		},
+	}
+}
+
+func getDeviceServiceVariableDataSourceSchema(sensitive bool) map[string]*schema.Schema {
+	s := getDeviceServiceKeySchema(false)
+	s["variable_name"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Required: true,
+	}
+	s["value"] = &schema.Schema{
+		Type:      schema.TypeString,
+		Computed:  true,
+		Sensitive: sensitive,
+	}
+	return s
+}
+
+func getDeviceServiceVariablesDataSourceSchema() map[string]*schema.Schema {
+	s := getDeviceServiceKeySchema(false)
+	s["variables"] = &schema.Schema{
+		Type:     schema.TypeMap,
+		Computed: true,
+	}
+	return s
+}
+
+// DescribeDeviceServiceVariables returns the variables of every service of a device
+func DescribeDeviceServiceVariables(client *api.Client, deviceUuid string) ([]DeviceServiceVariable, diag.Diagnostics) {
+	deviceServiceVariables, err := api.Get[DeviceServiceVariable](client, api.DeviceServiceEnvironmentVariable.All().
+		Filter(api.Eq("service_install/device/uuid", deviceUuid)).
+		Expand("service_install", api.Options().Select("id", "installs__service")))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Device Service Variables: %s", err)
+	}
+	return deviceServiceVariables, nil
+}
+
+// LookupDeviceServiceVariable finds a single variable of a service on a device, returning nil
+// without an error when the variable does not exist
+func LookupDeviceServiceVariable(client *api.Client, deviceUuid string, serviceId int, variableName string) (*DeviceServiceVariable, diag.Diagnostics) {
+	return lookupObject(func() ([]DeviceServiceVariable, diag.Diagnostics) {
+		return DescribeDeviceServiceVariables(client, deviceUuid)
+	}, func(variable DeviceServiceVariable) bool {
+		return variable.ServiceId() == serviceId && variable.Name == variableName
+	})
+}
+
+// FetchServiceInstall retrieves the service install linking a device to a service
+func FetchServiceInstall(client *api.Client, deviceUuid string, serviceId int) (*ServiceInstall, diag.Diagnostics) {
+	serviceInstalls, err := api.Get[ServiceInstall](client, api.ServiceInstall.All().
+		Filter(api.And(api.Eq("device/uuid", deviceUuid), api.Eq("installs__service", serviceId))))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Service Install: %s", err)
+	}
+
+	if len(serviceInstalls) == 0 {
+		return nil, diag.Errorf("the service %d is not installed on the device %s", serviceId, deviceUuid)
+	}
+
+	return &serviceInstalls[0], nil
+}
+
+// resolveDeviceService finds the service of the fleet of a device by its ID or name
+func resolveDeviceService(client *api.Client, deviceUuid string, serviceId int, serviceName string) (*Service, diag.Diagnostics) {
+	device, err := FetchDevice(client, deviceUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := DescribeServices(client, device.FleetId.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, service := range services {
+		if (serviceName != "" && service.Name == serviceName) || (serviceName == "" && service.Id == serviceId) {
+			return &service, nil
+		}
+	}
+
+	if serviceName != "" {
+		return nil, diag.Errorf("no service %s found in the fleet of the device %s", serviceName, deviceUuid)
+	}
+	return nil, diag.Errorf("no service %d found in the fleet of the device %s", serviceId, deviceUuid)
+}
+
+func GetDeviceServiceVariablesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	service, err := resolveDeviceService(client, deviceUuid, d.Get("service_id").(int), d.Get("service_name").(string))
+	if err != nil {
+		return err
+	}
+
+	variables, err := DescribeDeviceServiceVariables(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	for dataSourceAttribute := range getDeviceServiceVariablesDataSourceSchema() {
+		switch dataSourceAttribute {
+		case "device_uuid":
+			_ = d.Set("device_uuid", deviceUuid)
+		case "service_id":
+			_ = d.Set("service_id", service.Id)
+		case "service_name":
+			_ = d.Set("service_name", service.Name)
+		case "variables":
+			variableMap := make(map[string]string)
+			for _, variable := range variables {
+				if variable.ServiceId() == service.Id {
+					variableMap[variable.Name] = variable.Value
+				}
+			}
+			_ = d.Set("variables", variableMap)
+		default:
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+		}
+	}
+
+	d.SetId(GetPluralDeviceServiceVariableId(deviceUuid, service.Id))
+	return nil
+}
+
+func GetDeviceServiceVariableDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+	service, err := resolveDeviceService(client, deviceUuid, d.Get("service_id").(int), d.Get("service_name").(string))
+	if err != nil {
+		return err
+	}
+
+	variable, err := LookupDeviceServiceVariable(client, deviceUuid, service.Id, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return diag.Errorf("no variable %s configured for the service %s on the device %s", variableName, service.Name, deviceUuid)
+	}
+
+	for dataSourceAttribute := range getDeviceServiceVariableDataSourceSchema(false) {
+		switch dataSourceAttribute {
+		case "device_uuid":
+			_ = d.Set("device_uuid", deviceUuid)
+		case "service_id":
+			_ = d.Set("service_id", service.Id)
+		case "service_name":
+			_ = d.Set("service_name", service.Name)
+		case "variable_name":
+			_ = d.Set("variable_name", variableName)
+		case "value":
+			_ = d.Set("value", variable.Value)
+		default:
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+		}
+	}
+
+	d.SetId(GetSingularDeviceServiceVariableId(deviceUuid, service.Id, variableName))
+	return nil
+}
+
+func resourceDeviceServiceVariable() *schema.Resource {
+	return privateDeviceServiceVariableResource(false)
+}
+
+func resourceDeviceServiceVariableSensitive() *schema.Resource {
+	return privateDeviceServiceVariableResource(true)
+}
+
+func privateDeviceServiceVariableResource(sensitive bool) *schema.Resource {
+	s := getDeviceServiceKeySchema(true)
+	s["variable_name"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ForceNew:     true,
+		ValidateFunc: validateEnvironmentVariableName,
+	}
+	s["value"] = &schema.Schema{
+		Type:      schema.TypeString,
+		Required:  true,
+		Sensitive: sensitive,
+	}
+
+	return &schema.Resource{
+		CreateContext: ResourceDeviceServiceVariableCreate,
+		UpdateContext: ResourceDeviceServiceVariableUpdate,
+		ReadContext:   ResourceDeviceServiceVariableRead,
+		DeleteContext: ResourceDeviceServiceVariableDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceDeviceServiceVariableImport,
+		},
+		Description: "Manage a variable overriding a single service on a single device. " +
+			"It takes precedence over device, service and fleet variables with the same name.",
+		Schema: s,
+	}
+}
+
+func ResourceDeviceServiceVariableCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+	variableValue := d.Get("value").(string)
+
+	service, err := resolveDeviceService(client, deviceUuid, d.Get("service_id").(int), d.Get("service_name").(string))
+	if err != nil {
+		return err
+	}
+
+	existing, err := LookupDeviceServiceVariable(client, deviceUuid, service.Id, variableName)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return diag.Errorf("variable %s already exists", variableName)
+	}
+
+	serviceInstall, err := FetchServiceInstall(client, deviceUuid, service.Id)
+	if err != nil {
+		return err
+	}
+
+	err = CreateDeviceServiceVariable(client, serviceInstall.Id, variableName, variableValue)
+	if err != nil {
+		return err
+	}
+
+	_ = d.Set("service_id", service.Id)
+	_ = d.Set("service_name", service.Name)
+	d.SetId(GetSingularDeviceServiceVariableId(deviceUuid, service.Id, variableName))
+	return nil
+}
+
+// ResourceDeviceServiceVariableRead removes a variable deleted outside of Terraform from state
+func ResourceDeviceServiceVariableRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	serviceId := d.Get("service_id").(int)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupDeviceServiceVariable(client, deviceUuid, serviceId, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return removeFromState(d, "variable %s no longer exists for the service %d on the device %s", variableName, serviceId, deviceUuid)
+	}
+
+	_ = d.Set("device_uuid", deviceUuid)
+	_ = d.Set("service_id", serviceId)
+	_ = d.Set("variable_name", variable.Name)
+	_ = d.Set("value", variable.Value)
+	return nil
+}
+
+func ResourceDeviceServiceVariableUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	serviceId := d.Get("service_id").(int)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupDeviceServiceVariable(client, deviceUuid, serviceId, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return diag.Errorf("no variable %s configured for the service %d on the device %s", variableName, serviceId, deviceUuid)
+	}
+
+	return UpdateDeviceServiceVariable(client, variable.Id, d.Get("value").(string))
+}
+
+func ResourceDeviceServiceVariableDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	variable, err := LookupDeviceServiceVariable(client, d.Get("device_uuid").(string), d.Get("service_id").(int), d.Get("variable_name").(string))
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		// Already deleted outside of Terraform
+		return nil
+	}
+
+	return DeleteDeviceServiceVariable(client, variable.Id)
+}
+
+// ResourceDeviceServiceVariableImport accepts the resource ID
+// (`device-service-variable:<device_uuid>:<service_id>:<variable_name>`) as well as
+// `<device_uuid>/<service_id or service name>/<variable_name>`
+func ResourceDeviceServiceVariableImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	var parts []string
+	if strings.HasPrefix(d.Id(), "device-service-variable:") {
+		parts = strings.SplitN(strings.TrimPrefix(d.Id(), "device-service-variable:"), ":", 3)
+	} else {
+		parts = strings.SplitN(d.Id(), "/", 3)
+	}
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid import ID %q, expected <device_uuid>/<service_id or service name>/<variable_name>", d.Id())
+	}
+
+	serviceId, serviceName := -1, parts[1]
+	if id, err := strconv.Atoi(parts[1]); err == nil {
+		serviceId, serviceName = id, ""
+	}
+
+	service, diags := resolveDeviceService(client, parts[0], serviceId, serviceName)
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+
+	_ = d.Set("device_uuid", parts[0])
+	_ = d.Set("service_id", service.Id)
+	_ = d.Set("service_name", service.Name)
+	_ = d.Set("variable_name", parts[2])
+	d.SetId(GetSingularDeviceServiceVariableId(parts[0], service.Id, parts[2]))
+	return []*schema.ResourceData{d}, nil
+}
+
+func CreateDeviceServiceVariable(client *api.Client, serviceInstallId int, variableName string, variableValue string) diag.Diagnostics {
+	if _, err := api.Create[DeviceServiceVariable](client, api.DeviceServiceEnvironmentVariable, map[string]interface{}{
+		"service_install": serviceInstallId,
+		"name":            variableName,
+		"value":           variableValue,
+	}); err != nil {
+		return diag.Errorf("error creating device service variable: %s", err)
+	}
+	return nil
+}
+
+func UpdateDeviceServiceVariable(client *api.Client, deviceServiceVariableId int, variableValue string) diag.Diagnostics {
+	if err := client.Patch(api.DeviceServiceEnvironmentVariable.ID(deviceServiceVariableId), map[string]interface{}{
+		"value": variableValue,
+	}); err != nil {
+		return diag.Errorf("error updating device service variable: %s", err)
+	}
+	return nil
+}
+
+func DeleteDeviceServiceVariable(client *api.Client, deviceServiceVariableId int) diag.Diagnostics {
+	if err := client.Delete(api.DeviceServiceEnvironmentVariable.ID(deviceServiceVariableId)); err != nil {
+		return diag.Errorf("error deleting device service variable: %s", err)
+	}
+	return nil
+}
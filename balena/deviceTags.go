@@ -2,10 +2,11 @@ package balena
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strings"
 )
 
 type DeviceTag struct {
@@ -14,14 +15,14 @@ type DeviceTag struct {
 	Value string `json:"value"`
 }
 
-type DeviceTagResponse struct {
-	Tags []DeviceTag `json:"d"`
-}
-
 func GetDeviceTagsId(deviceUuid string) string {
 	return fmt.Sprintf("device-tags:%s", deviceUuid)
 }
 
+func GetDeviceTagId(deviceUuid string, tagKey string) string {
+	return fmt.Sprintf("device-tag:%s:%s", deviceUuid, tagKey)
+}
+
 func dataSourceDeviceTags() *schema.Resource {
 	return &schema.Resource{
 		ReadContext: GetDeviceTagsDataSource,
@@ -45,31 +46,20 @@ func getDeviceTagsDataSourceSchema() map[string]*schema.Schema {
 	}
 }
 
-func FetchDeviceTags(uuid string) ([]DeviceTag, diag.Diagnostics) {
-	endpoint := fmt.Sprintf("/v7/device_tag?$filter=%s", fmt.Sprintf("device/uuid eq '%s'", uuid))
-	res, err := client.client.R().Get(endpoint)
-	if err != nil || (res != nil && !is200Level(res.StatusCode())) {
-		var message string
-		if err != nil {
-			message = fmt.Sprintf("with the statuscode %d", res.StatusCode())
-		} else {
-			message = fmt.Sprintf("with the error %s", err)
-		}
-		return nil, diag.Errorf("retrieving device tags for %s failed %s", uuid, message)
-	}
-
-	var deviceTagsResponse DeviceTagResponse
-	if err := json.Unmarshal(res.Body(), &deviceTagsResponse); err != nil {
-		return nil, diag.FromErr(err)
+func FetchDeviceTags(client *api.Client, uuid string) ([]DeviceTag, diag.Diagnostics) {
+	tags, err := api.Get[DeviceTag](client, api.DeviceTag.All().Filter(api.Eq("device/uuid", uuid)))
+	if err != nil {
+		return nil, diag.Errorf("retrieving device tags for %s failed with the error %s", uuid, err)
 	}
-
-	return deviceTagsResponse.Tags, nil
+	return tags, nil
 }
 
-func GetDeviceTagsDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func GetDeviceTagsDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	deviceUuid := d.Get("device_uuid").(string)
 
-	tags, err := FetchDeviceTags(deviceUuid)
+	tags, err := FetchDeviceTags(client, deviceUuid)
 	if err != nil {
 		return err
 	}
@@ -92,3 +82,278 @@ func GetDeviceTagsDataSource(_ context.Context, d *schema.ResourceData, _ interf
 	d.SetId(GetDeviceTagsId(deviceUuid))
 	return nil
 }
+
+func resourceDeviceTag() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceDeviceTagCreate,
+		ReadContext:   ResourceDeviceTagRead,
+		UpdateContext: ResourceDeviceTagUpdate,
+		DeleteContext: ResourceDeviceTagDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceDeviceTagImport,
+		},
+		Description: "Manage a single tag of a device. Do not combine with the `balena_device_tags` resource for the same device.",
+		Schema: map[string]*schema.Schema{
+			"device_uuid": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The UUID of the device to tag.",
+			},
+			"tag_key": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The key of the tag.",
+			},
+			"value": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The value of the tag.",
+			},
+		},
+	}
+}
+
+func resourceDeviceTags() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceDeviceTagsCreate,
+		ReadContext:   ResourceDeviceTagsRead,
+		UpdateContext: ResourceDeviceTagsUpdate,
+		DeleteContext: ResourceDeviceTagsDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceDeviceTagsImport,
+		},
+		Description: "Authoritatively manage all tags of a device: tags that are not configured are removed from the device.",
+		Schema: map[string]*schema.Schema{
+			"device_uuid": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The UUID of the device to tag.",
+			},
+			"tags": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "A key-value pair of device tags. Leaving it empty removes every tag from the device.",
+			},
+		},
+	}
+}
+
+func existingDeviceTags(tags []DeviceTag) map[string]existingTag {
+	existing := make(map[string]existingTag)
+	for _, tag := range tags {
+		existing[tag.Key] = existingTag{id: tag.Id, value: tag.Value}
+	}
+	return existing
+}
+
+func ResourceDeviceTagCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	tagKey := d.Get("tag_key").(string)
+
+	device, err := FetchDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	tags, err := FetchDeviceTags(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+	if _, ok := existingDeviceTags(tags)[tagKey]; ok {
+		return diag.Errorf("tag %s already exists on the device %s", tagKey, deviceUuid)
+	}
+
+	if err := CreateTag(client, api.DeviceTag, "device", device.Id, tagKey, d.Get("value").(string)); err != nil {
+		return err
+	}
+
+	d.SetId(GetDeviceTagId(deviceUuid, tagKey))
+	return ResourceDeviceTagRead(ctx, d, m)
+}
+
+// ResourceDeviceTagRead removes a tag deleted outside of Terraform from state
+func ResourceDeviceTagRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	tagKey := d.Get("tag_key").(string)
+
+	tags, err := FetchDeviceTags(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingDeviceTags(tags)[tagKey]
+	if !ok {
+		return removeFromState(d, "tag %s no longer exists on the device %s", tagKey, deviceUuid)
+	}
+
+	_ = d.Set("value", tag.value)
+	return nil
+}
+
+func ResourceDeviceTagUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	tagKey := d.Get("tag_key").(string)
+
+	tags, err := FetchDeviceTags(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingDeviceTags(tags)[tagKey]
+	if !ok {
+		return diag.Errorf("no tag %s configured for the device %s", tagKey, deviceUuid)
+	}
+
+	if err := UpdateTag(client, api.DeviceTag, tag.id, d.Get("value").(string)); err != nil {
+		return err
+	}
+	return ResourceDeviceTagRead(ctx, d, m)
+}
+
+func ResourceDeviceTagDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	tags, err := FetchDeviceTags(client, d.Get("device_uuid").(string))
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingDeviceTags(tags)[d.Get("tag_key").(string)]
+	if !ok {
+		// Already deleted outside of Terraform
+		return nil
+	}
+
+	return DeleteTag(client, api.DeviceTag, tag.id)
+}
+
+// ResourceDeviceTagImport accepts the resource ID (`device-tag:<device_uuid>:<tag_key>`)
+// as well as `<device_uuid>/<tag_key>`
+func ResourceDeviceTagImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	var parts []string
+	if strings.HasPrefix(d.Id(), "device-tag:") {
+		parts = strings.SplitN(strings.TrimPrefix(d.Id(), "device-tag:"), ":", 2)
+	} else {
+		parts = strings.SplitN(d.Id(), "/", 2)
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid import ID %q, expected <device_uuid>/<tag_key>", d.Id())
+	}
+
+	_ = d.Set("device_uuid", parts[0])
+	_ = d.Set("tag_key", parts[1])
+	d.SetId(GetDeviceTagId(parts[0], parts[1]))
+	return []*schema.ResourceData{d}, nil
+}
+
+func ResourceDeviceTagsCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+
+	device, err := FetchDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetDeviceTagsId(deviceUuid))
+	if err := convergeDeviceTags(client, device.Id, deviceUuid, d.Get("tags").(map[string]interface{})); err != nil {
+		return err
+	}
+
+	return ResourceDeviceTagsRead(ctx, d, m)
+}
+
+// ResourceDeviceTagsRead reads every tag of the device, so that tags edited
+// outside of Terraform show up as drift
+func ResourceDeviceTagsRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+
+	device, err := LookupDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		return removeFromState(d, "the device %s of the tags no longer exists", deviceUuid)
+	}
+
+	tags, err := FetchDeviceTags(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	newTags := map[string]string{}
+	for _, deviceTag := range tags {
+		newTags[deviceTag.Key] = deviceTag.Value
+	}
+	_ = d.Set("tags", newTags)
+	return nil
+}
+
+func ResourceDeviceTagsUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+
+	device, err := FetchDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	if err := convergeDeviceTags(client, device.Id, deviceUuid, d.Get("tags").(map[string]interface{})); err != nil {
+		return err
+	}
+
+	return ResourceDeviceTagsRead(ctx, d, m)
+}
+
+func ResourceDeviceTagsDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+
+	device, err := LookupDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		return nil
+	}
+
+	return convergeDeviceTags(client, device.Id, deviceUuid, map[string]interface{}{})
+}
+
+// ResourceDeviceTagsImport accepts the resource ID (`device-tags:<device_uuid>`) or a device UUID
+func ResourceDeviceTagsImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	deviceUuid := strings.TrimPrefix(d.Id(), "device-tags:")
+
+	_ = d.Set("device_uuid", deviceUuid)
+	d.SetId(GetDeviceTagsId(deviceUuid))
+	return []*schema.ResourceData{d}, nil
+}
+
+func convergeDeviceTags(client *api.Client, deviceId int, deviceUuid string, desired map[string]interface{}) diag.Diagnostics {
+	tags, err := FetchDeviceTags(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	desiredTags := make(map[string]string)
+	for key, value := range desired {
+		desiredTags[key] = value.(string)
+	}
+
+	return ConvergeTags(client, api.DeviceTag, "device", deviceId, existingDeviceTags(tags), desiredTags)
+}
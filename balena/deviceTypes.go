@@ -1 +1,255 @@
 package balena
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+)
+
+// DeviceType is the format device types are returned from the Balena API
+// when expanding their CPU architecture and the fleets they are the default for
+type DeviceType struct {
+	Id              int                    `json:"id"`
+	Slug            string                 `json:"slug"`
+	Name            string                 `json:"name"`
+	Logo            string                 `json:"logo"`
+	Contract        json.RawMessage        `json:"contract"`
+	CpuArchitecture []CpuArchitecture      `json:"is_of__cpu_architecture"`
+	DefaultFor      []DeviceTypeDefaultFor `json:"is_default_for__application"`
+}
+
+type DeviceTypeDefaultFor struct {
+	Id int `json:"id"`
+}
+
+// Architecture returns the ID and slug of the CPU architecture of the device type
+func (t DeviceType) Architecture() (int, string) {
+	if len(t.CpuArchitecture) == 0 {
+		return 0, ""
+	}
+	return t.CpuArchitecture[0].Id, t.CpuArchitecture[0].Slug
+}
+
+func GetDeviceTypeId(deviceTypeId int) string {
+	return fmt.Sprintf("device-type:%d", deviceTypeId)
+}
+
+func getDeviceTypeDataSourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"device_type_id": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Computed:     true,
+			ExactlyOneOf: []string{"device_type_id", "slug"},
+			Description:  "The ID of the device type. Exactly one of `device_type_id` and `slug` must be set.",
+		},
+		"slug": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			ExactlyOneOf: []string{"device_type_id", "slug"},
+			Description:  "The slug of the device type, e.g. `raspberrypi4-64`.",
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The display name of the device type.",
+		},
+		"architecture": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The slug of the CPU architecture of the device type, e.g. `aarch64`. See the `balena_cpu_architecture` data source.",
+		},
+		"cpu_architecture_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the CPU architecture of the device type.",
+		},
+		"is_default_for": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeInt},
+			Description: "The IDs of the fleets, visible to the provider, that use this device type as their default.",
+		},
+		"logo": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The logo of the device type, as a data URI.",
+		},
+		"contract": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The JSON-encoded contract of the device type, describing its hardware and capabilities.",
+		},
+	}
+}
+
+func getDeviceTypesDataSourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"architecture": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Only list device types of this CPU architecture, e.g. `aarch64`.",
+		},
+		"device_types": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The device types, without their logo and contract.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"device_type_id": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					"slug": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"architecture": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"cpu_architecture_id": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceDeviceType() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceTypeDataSource,
+		Schema:      getDeviceTypeDataSourceSchema(),
+		Description: "Retrieve information about a device type given its `device_type_id` or `slug`.",
+	}
+}
+
+func dataSourceDeviceTypes() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceTypesDataSource,
+		Schema:      getDeviceTypesDataSourceSchema(),
+		Description: "List the device types supported by Balena.",
+	}
+}
+
+// DescribeDeviceTypes lists the device types of a query on the `device_type` resource
+func DescribeDeviceTypes(client *api.Client, query *api.Query) ([]DeviceType, diag.Diagnostics) {
+	deviceTypes, err := api.Get[DeviceType](client, query)
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Device Types: %s", err)
+	}
+	return deviceTypes, nil
+}
+
+func FetchDeviceType(client *api.Client, slug string, deviceTypeId int) (*DeviceType, diag.Diagnostics) {
+	var filter api.Filter
+	if slug != "" {
+		filter = api.Eq("slug", slug)
+	} else {
+		filter = api.Eq("id", deviceTypeId)
+	}
+
+	deviceTypes, err := DescribeDeviceTypes(client, api.DeviceType.All().
+		Expand("is_of__cpu_architecture", api.Options().Select("id", "slug")).
+		Expand("is_default_for__application", api.Options().Select("id")).
+		Filter(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deviceTypes) == 0 {
+		return nil, diag.Errorf("no device type found")
+	} else if len(deviceTypes) > 1 {
+		return nil, diag.Errorf("more than one device type found")
+	}
+
+	return &deviceTypes[0], nil
+}
+
+func GetDeviceTypeDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceType, err := FetchDeviceType(client, d.Get("slug").(string), d.Get("device_type_id").(int))
+	if err != nil {
+		return err
+	}
+
+	cpuArchitectureId, architecture := deviceType.Architecture()
+	for dataSourceAttribute := range getDeviceTypeDataSourceSchema() {
+		switch dataSourceAttribute {
+		case "device_type_id":
+			_ = d.Set("device_type_id", deviceType.Id)
+		case "slug":
+			_ = d.Set("slug", deviceType.Slug)
+		case "name":
+			_ = d.Set("name", deviceType.Name)
+		case "architecture":
+			_ = d.Set("architecture", architecture)
+		case "cpu_architecture_id":
+			_ = d.Set("cpu_architecture_id", cpuArchitectureId)
+		case "is_default_for":
+			fleetIds := make([]int, 0)
+			for _, fleet := range deviceType.DefaultFor {
+				fleetIds = append(fleetIds, fleet.Id)
+			}
+			_ = d.Set("is_default_for", fleetIds)
+		case "logo":
+			_ = d.Set("logo", deviceType.Logo)
+		case "contract":
+			_ = d.Set("contract", string(deviceType.Contract))
+		default:
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+		}
+	}
+
+	d.SetId(GetDeviceTypeId(deviceType.Id))
+	return nil
+}
+
+func GetDeviceTypesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	architecture := d.Get("architecture").(string)
+
+	var filter api.Filter
+	if architecture != "" {
+		filter = api.Eq("is_of__cpu_architecture/slug", architecture)
+	}
+
+	deviceTypes, err := DescribeDeviceTypes(client, api.DeviceType.All().
+		Select("id", "slug", "name").
+		Expand("is_of__cpu_architecture", api.Options().Select("id", "slug")).
+		Filter(filter).
+		OrderBy("slug asc"))
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, deviceType := range deviceTypes {
+		cpuArchitectureId, architectureSlug := deviceType.Architecture()
+		response = append(response, map[string]interface{}{
+			"device_type_id":      deviceType.Id,
+			"slug":                deviceType.Slug,
+			"name":                deviceType.Name,
+			"architecture":        architectureSlug,
+			"cpu_architecture_id": cpuArchitectureId,
+		})
+	}
+	if err := d.Set("device_types", response); err != nil {
+		return diag.Errorf("failed to set device_types: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("device-types:%s", architecture))
+	return nil
+}
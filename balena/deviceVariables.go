@@ -1,29 +1,83 @@
 package balena
 
-// TODO Figure out the correct order of hierarchy
-
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strings"
 )
 
+// DeviceVariable is the format that device variables are returned
+// from the Balena API
 type DeviceVariable struct {
+	Id      int    `json:"id"`
 	Name    string `json:"name"`
 	Value   string `json:"value"`
 	Created string `json:"created_at"`
 }
 
-type DeviceVariablesResponse struct {
-	DeviceVariables []DeviceVariable `json:"d"`
+func GetSingularDeviceVariableId(deviceUuid string, variableName string) string {
+	return fmt.Sprintf("device-variable:%s:%s", deviceUuid, variableName)
+}
+
+func GetPluralDeviceVariableId(deviceUuid string) string {
+	return fmt.Sprintf("device-variable:%s", deviceUuid)
+}
+
+func GetEffectiveVariablesId(deviceUuid string) string {
+	return fmt.Sprintf("effective-variables:%s", deviceUuid)
+}
+
+func dataSourceDeviceVariable() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceVariableDataSource,
+		Schema:      getDeviceVariableDataSourceSchema(false),
+	}
+}
+
+func dataSourceDeviceVariableSensitive() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDeviceVariableDataSource,
+		Schema:      getDeviceVariableDataSourceSchema(true),
+	}
 }
 
 func dataSourceDeviceVariables() *schema.Resource {
 	return &schema.Resource{
 		ReadContext: GetDeviceVariablesDataSource,
 		Schema:      getDeviceVariablesDataSourceSchema(),
+		Description: "Get the variables configured directly on a device, including the overrides of its individual services. " +
+			"These do not include the fleet variables the device inherits, see `balena_effective_variables` for those.",
+	}
+}
+
+// dataSourceEffectiveVariables resolves the variables a device will actually run with
+func dataSourceEffectiveVariables() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetEffectiveVariablesDataSource,
+		Schema:      getEffectiveVariablesDataSourceSchema(),
+		Description: "Resolve the variables a device runs with, the way the supervisor does. From lowest to highest precedence: " +
+			"fleet variables, service variables, device variables and device service variables.",
+	}
+}
+
+func getDeviceVariableDataSourceSchema(sensitive bool) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"device_uuid": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		"variable_name": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		"value": {
+			Type:      schema.TypeString,
+			Computed:  true,
+			Sensitive: sensitive,
+		},
 	}
 }
 
@@ -37,47 +91,466 @@ func getDeviceVariablesDataSourceSchema() map[string]*schema.Schema {
 			Type:     schema.TypeMap,
 			Computed: true,
 		},
+		"service_variables": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The variables overriding individual services on the device.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"service_id": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					"service_name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"variables": {
+						Type:     schema.TypeMap,
+						Computed: true,
+					},
+				},
+			},
+		},
 	}
 }
 
-func DescribeDeviceVariables(deviceUuid string) ([]DeviceVariable, diag.Diagnostics) {
-	endpoint := fmt.Sprintf("/v7/device_environment_variable?$filter=%s", fmt.Sprintf("device/any(d:d/uuid eq '%s')", deviceUuid))
-	res, err := client.client.R().Get(endpoint)
-	if err != nil {
-		return nil, diag.FromErr(err)
-	}
-	if !is200Level(res.StatusCode()) {
-		return nil, diag.FromErr(fmt.Errorf("error retrieving Fleet Variables: %s", res.Status()))
+func getEffectiveVariablesDataSourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"device_uuid": {
+			Type:        schema.TypeString,
+			Required:    true,
+			Description: "The UUID of the device.",
+		},
+		"fleet_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the fleet the device belongs to, from which the device inherits variables.",
+		},
+		"variables": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "The merged fleet and device variables, which apply to every service of the device.",
+		},
+		"variable_sources": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "For every variable, where its effective value is configured: either `fleet` or `device`.",
+		},
+		"service_variables": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The variables each service of the device runs with.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"service_id": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					"service_name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"variables": {
+						Type:        schema.TypeMap,
+						Computed:    true,
+						Description: "The merged variables of the service.",
+					},
+					"variable_sources": {
+						Type:        schema.TypeMap,
+						Computed:    true,
+						Description: "For every variable, where its effective value is configured: `fleet`, `service`, `device` or `device_service`.",
+					},
+				},
+			},
+		},
 	}
+}
 
-	var deviceVariables DeviceVariablesResponse
-	if err := json.Unmarshal(res.Body(), &deviceVariables); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("failed to unmarshal response from Balena device variables API: %w", err))
+func DescribeDeviceVariables(client *api.Client, deviceUuid string) ([]DeviceVariable, diag.Diagnostics) {
+	deviceVariables, err := api.Get[DeviceVariable](client, api.DeviceEnvironmentVariable.All().
+		Filter(api.Eq("device/uuid", deviceUuid)))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Device Variables: %s", err)
 	}
-
-	return deviceVariables.DeviceVariables, nil
+	return deviceVariables, nil
 }
 
-func GetDeviceVariablesDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
-	uuid := d.Get("device_uuid").(string)
-	variables, err := DescribeDeviceVariables(uuid)
+func GetDeviceVariablesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	device, err := FetchDevice(client, deviceUuid)
 	if err != nil {
 		return err
 	}
 
-	for dataSourceAttribute := range getFleetVariablesDataSourceSchema() {
+	variables, err := DescribeDeviceVariables(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+	services, err := DescribeServices(client, device.FleetId.ID)
+	if err != nil {
+		return err
+	}
+	deviceServiceVariables, err := DescribeDeviceServiceVariables(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	for dataSourceAttribute := range getDeviceVariablesDataSourceSchema() {
 		switch dataSourceAttribute {
-		case "deviceUuid":
-			_ = d.Set("device_uuid", uuid)
+		case "device_uuid":
+			_ = d.Set("device_uuid", deviceUuid)
 		case "variables":
 			variableMap := make(map[string]string)
 			for _, variable := range variables {
 				variableMap[variable.Name] = variable.Value
 			}
 			_ = d.Set("variables", variableMap)
+		case "service_variables":
+			response := make([]map[string]interface{}, 0)
+			for _, service := range services {
+				variableMap := make(map[string]string)
+				for _, variable := range deviceServiceVariables {
+					if variable.ServiceId() == service.Id {
+						variableMap[variable.Name] = variable.Value
+					}
+				}
+				if len(variableMap) == 0 {
+					continue
+				}
+				response = append(response, map[string]interface{}{
+					"service_id":   service.Id,
+					"service_name": service.Name,
+					"variables":    variableMap,
+				})
+			}
+			if err := d.Set("service_variables", response); err != nil {
+				return diag.Errorf("failed to set service_variables: %v", err)
+			}
+		default:
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+		}
+	}
+
+	d.SetId(GetPluralDeviceVariableId(deviceUuid))
+	return nil
+}
+
+func GetDeviceVariableDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupDeviceVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return diag.Errorf("no variable %s configured for the device %s", variableName, deviceUuid)
+	}
+
+	for dataSourceAttribute := range getDeviceVariableDataSourceSchema(false) {
+		switch dataSourceAttribute {
+		case "device_uuid":
+			_ = d.Set("device_uuid", deviceUuid)
+		case "variable_name":
+			_ = d.Set("variable_name", variableName)
+		case "value":
+			_ = d.Set("value", variable.Value)
+		default:
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
 		}
 	}
 
-	d.SetId(fmt.Sprintf("deviceVariables:%s", uuid))
+	d.SetId(GetSingularDeviceVariableId(deviceUuid, variableName))
+	return nil
+}
+
+// GetEffectiveVariablesDataSource merges the variables of a device following the supervisor's hierarchy:
+// fleet variables < service variables < device variables < device service variables
+func GetEffectiveVariablesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	device, err := FetchDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	fleetVariables, err := DescribeFleetVariables(client, device.FleetId.ID)
+	if err != nil {
+		return err
+	}
+	deviceVariables, err := DescribeDeviceVariables(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+	services, err := DescribeServices(client, device.FleetId.ID)
+	if err != nil {
+		return err
+	}
+	deviceServiceVariables, err := DescribeDeviceServiceVariables(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	variableMap := make(map[string]string)
+	sourceMap := make(map[string]string)
+	for _, variable := range fleetVariables {
+		variableMap[variable.Name] = variable.Value
+		sourceMap[variable.Name] = "fleet"
+	}
+	for _, variable := range deviceVariables {
+		variableMap[variable.Name] = variable.Value
+		sourceMap[variable.Name] = "device"
+	}
+
+	serviceResponse := make([]map[string]interface{}, 0)
+	for _, service := range services {
+		serviceVariables, err := ServiceVariablesApiCall(client, service.Id)
+		if err != nil {
+			return err
+		}
+
+		serviceVariableMap := make(map[string]string)
+		serviceSourceMap := make(map[string]string)
+		for _, variable := range fleetVariables {
+			serviceVariableMap[variable.Name] = variable.Value
+			serviceSourceMap[variable.Name] = "fleet"
+		}
+		for _, variable := range serviceVariables {
+			serviceVariableMap[variable.Name] = variable.Value
+			serviceSourceMap[variable.Name] = "service"
+		}
+		for _, variable := range deviceVariables {
+			serviceVariableMap[variable.Name] = variable.Value
+			serviceSourceMap[variable.Name] = "device"
+		}
+		for _, variable := range deviceServiceVariables {
+			if variable.ServiceId() == service.Id {
+				serviceVariableMap[variable.Name] = variable.Value
+				serviceSourceMap[variable.Name] = "device_service"
+			}
+		}
+
+		serviceResponse = append(serviceResponse, map[string]interface{}{
+			"service_id":       service.Id,
+			"service_name":     service.Name,
+			"variables":        serviceVariableMap,
+			"variable_sources": serviceSourceMap,
+		})
+	}
+
+	for dataSourceAttribute := range getEffectiveVariablesDataSourceSchema() {
+		switch dataSourceAttribute {
+		case "device_uuid":
+			_ = d.Set("device_uuid", deviceUuid)
+		case "fleet_id":
+			_ = d.Set("fleet_id", device.FleetId.ID)
+		case "variables":
+			_ = d.Set("variables", variableMap)
+		case "variable_sources":
+			_ = d.Set("variable_sources", sourceMap)
+		case "service_variables":
+			if err := d.Set("service_variables", serviceResponse); err != nil {
+				return diag.Errorf("failed to set service_variables: %v", err)
+			}
+		default:
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+		}
+	}
+
+	d.SetId(GetEffectiveVariablesId(deviceUuid))
+	return nil
+}
+
+func resourceDeviceVariable() *schema.Resource {
+	return privateDeviceVariableResource(false)
+}
+
+func resourceDeviceVariableSensitive() *schema.Resource {
+	return privateDeviceVariableResource(true)
+}
+
+func privateDeviceVariableResource(sensitive bool) *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceDeviceVariableCreate,
+		UpdateContext: ResourceDeviceVariableUpdate,
+		ReadContext:   ResourceDeviceVariableRead,
+		DeleteContext: ResourceDeviceVariableDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceDeviceVariableImport,
+		},
+		Description: "Manage a variable of a single device. A device variable overrides the fleet variable with the same name.",
+		Schema: map[string]*schema.Schema{
+			"device_uuid": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"variable_name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateEnvironmentVariableName,
+			},
+			"value": {
+				Type:      schema.TypeString,
+				Required:  true,
+				Sensitive: sensitive,
+			},
+		},
+	}
+}
+
+// LookupDeviceVariable finds a single variable of a device, returning nil without an error
+// when the variable does not exist
+func LookupDeviceVariable(client *api.Client, deviceUuid string, variableName string) (*DeviceVariable, diag.Diagnostics) {
+	return lookupObject(func() ([]DeviceVariable, diag.Diagnostics) {
+		return DescribeDeviceVariables(client, deviceUuid)
+	}, func(variable DeviceVariable) bool {
+		return variable.Name == variableName
+	})
+}
+
+func ResourceDeviceVariableCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+	variableValue := d.Get("value").(string)
+
+	device, err := FetchDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+
+	existing, err := LookupDeviceVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return diag.Errorf("variable %s already exists", variableName)
+	}
+
+	err = CreateDeviceVariable(client, device.Id, variableName, variableValue)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetSingularDeviceVariableId(deviceUuid, variableName))
+	return nil
+}
+
+// ResourceDeviceVariableRead removes a variable deleted outside of Terraform from state
+func ResourceDeviceVariableRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupDeviceVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return removeFromState(d, "variable %s no longer exists for the device %s", variableName, deviceUuid)
+	}
+
+	_ = d.Set("device_uuid", deviceUuid)
+	_ = d.Set("variable_name", variable.Name)
+	_ = d.Set("value", variable.Value)
+	return nil
+}
+
+func ResourceDeviceVariableUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+	variableValue := d.Get("value").(string)
+
+	variable, err := LookupDeviceVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		return diag.Errorf("no variable %s configured for the device %s", variableName, deviceUuid)
+	}
+
+	return UpdateDeviceVariable(client, variable.Id, variableValue)
+}
+
+func ResourceDeviceVariableDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := d.Get("device_uuid").(string)
+	variableName := d.Get("variable_name").(string)
+
+	variable, err := LookupDeviceVariable(client, deviceUuid, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
+		// Already deleted outside of Terraform
+		return nil
+	}
+
+	return DeleteDeviceVariable(client, variable.Id)
+}
+
+// ResourceDeviceVariableImport accepts the resource ID (`device-variable:<device_uuid>:<variable_name>`)
+// as well as `<device_uuid>/<variable_name>`
+func ResourceDeviceVariableImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	var parts []string
+	if strings.HasPrefix(d.Id(), "device-variable:") {
+		parts = strings.SplitN(strings.TrimPrefix(d.Id(), "device-variable:"), ":", 2)
+	} else {
+		parts = strings.SplitN(d.Id(), "/", 2)
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid import ID %q, expected <device_uuid>/<variable_name>", d.Id())
+	}
+
+	device, diags := FetchDevice(client, parts[0])
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+
+	_ = d.Set("device_uuid", device.Uuid)
+	_ = d.Set("variable_name", parts[1])
+	d.SetId(GetSingularDeviceVariableId(device.Uuid, parts[1]))
+	return []*schema.ResourceData{d}, nil
+}
+
+func CreateDeviceVariable(client *api.Client, deviceId int, variableName string, variableValue string) diag.Diagnostics {
+	if _, err := api.Create[DeviceVariable](client, api.DeviceEnvironmentVariable, map[string]interface{}{
+		"device": deviceId,
+		"name":   variableName,
+		"value":  variableValue,
+	}); err != nil {
+		return diag.Errorf("error creating device variable: %s", err)
+	}
+	return nil
+}
+
+func UpdateDeviceVariable(client *api.Client, deviceVariableId int, variableValue string) diag.Diagnostics {
+	if err := client.Patch(api.DeviceEnvironmentVariable.ID(deviceVariableId), map[string]interface{}{
+		"value": variableValue,
+	}); err != nil {
+		return diag.Errorf("error updating device variable: %s", err)
+	}
+	return nil
+}
+
+func DeleteDeviceVariable(client *api.Client, deviceVariableId int) diag.Diagnostics {
+	if err := client.Delete(api.DeviceEnvironmentVariable.ID(deviceVariableId)); err != nil {
+		return diag.Errorf("error deleting device variable: %s", err)
+	}
 	return nil
 }
@@ -2,21 +2,22 @@ package balena
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"log"
+	"github.com/kassett/terraform-provider-balena/balena/api"
 	"strings"
 )
 
 type Device struct {
+	Id                    int       `json:"id"`
 	Uuid                  string    `json:"uuid"`
 	DeviceName            string    `json:"device_name"`
 	LastVpnEvent          string    `json:"last_vpn_event"`
 	LastConnectivityEvent string    `json:"last_connectivity_event"`
 	IpAddress             string    `json:"ip_address"`
-	MacAddresses          string    `json:"mac_addresses"`
+	MacAddresses          string    `json:"mac_address"`
 	PublicAddress         string    `json:"public_address"`
 	SupervisorVersion     string    `json:"supervisor_version"`
 	OsVersion             string    `json:"os_version"`
@@ -29,12 +30,14 @@ type Device struct {
 	Description           string    `json:"note"`
 	Created               string    `json:"created_at"`
 	RunningReleaseId      IDWrapper `json:"is_running__release"`
-	PinnedReleaseId       string    `json:"is_pinned_on__release"`
+	PinnedReleaseId       IDWrapper `json:"is_pinned_on__release"`
+	IsOnline              bool      `json:"is_online"`
 }
 
-type DeviceResponse struct {
-	Devices []Device `json:"d"`
-}
+// deviceFields are the fields of Device, requested explicitly when listing devices
+var deviceFields = []string{"id", "uuid", "device_name", "last_vpn_event", "last_connectivity_event", "ip_address", "mac_address", "public_address",
+	"supervisor_version", "os_version", "longitude", "latitude", "custom_longitude", "custom_latitude", "is_of__device_type",
+	"belongs_to__application", "note", "created_at", "is_running__release", "is_pinned_on__release", "is_online"}
 
 func GetDeviceId(deviceUuid string) string {
 	return fmt.Sprintf("device:%s", deviceUuid)
@@ -113,7 +116,7 @@ func getDeviceDataSourceSchema() map[string]*schema.Schema {
 		"device_type_id": {
 			Type:        schema.TypeInt,
 			Computed:    true,
-			Description: "The ID of the device type. These IDs can be retrieved via the `device_type` API.",
+			Description: "The ID of the device type. These IDs can be retrieved via the `balena_device_type` data source.",
 		},
 		"fleet_id": {
 			Type:        schema.TypeInt,
@@ -140,31 +143,81 @@ func getDeviceDataSourceSchema() map[string]*schema.Schema {
 			Computed:    true,
 			Description: "The ID of the pinned release of the device. If the device is tracking latest, this ID will be null.",
 		},
+		"is_online": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the device is currently connected to Balena.",
+		},
 	}
 }
 
-func FetchDevice(uuid string) (*Device, diag.Diagnostics) {
-	endpoint := fmt.Sprintf("/v7/device(uuid='%s')", uuid)
-	res, err := client.client.R().Get(endpoint)
-	if err != nil {
-		return nil, diag.FromErr(err)
+// LookupDevice retrieves a device by UUID, returning nil without an error
+// when Balena has no such device so that resources can drop it from state
+func LookupDevice(client *api.Client, uuid string) (*Device, diag.Diagnostics) {
+	devices, err := api.Get[Device](client, api.Device.Key("uuid", uuid))
+	if api.IsNotFound(err) {
+		return nil, nil
 	}
-	if !is200Level(res.StatusCode()) {
-		return nil, diag.FromErr(fmt.Errorf("error retrieving Device: %d", res.StatusCode()))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Device: %s", err)
 	}
 
-	var deviceResponse DeviceResponse
-	if err := json.Unmarshal(res.Body(), &deviceResponse); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("failed to unmarshal response from Balena device API: %w", err))
+	if len(devices) == 0 {
+		return nil, nil
+	} else if len(devices) > 1 {
+		return nil, diag.Errorf("more than one device found")
 	}
 
-	if len(deviceResponse.Devices) == 0 {
+	return &devices[0], nil
+}
+
+func FetchDevice(client *api.Client, uuid string) (*Device, diag.Diagnostics) {
+	device, err := LookupDevice(client, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
 		return nil, diag.Errorf("no device found")
-	} else if len(deviceResponse.Devices) > 1 {
-		return nil, diag.Errorf("more than one device found")
 	}
+	return device, nil
+}
 
-	return &deviceResponse.Devices[0], nil
+// DescribeDevices lists the devices matching an OData filter, ordered by name
+func DescribeDevices(client *api.Client, filter api.Filter) ([]Device, diag.Diagnostics) {
+	devices, err := api.Get[Device](client, api.Device.All().
+		Select(deviceFields...).
+		Filter(filter).
+		OrderBy("device_name asc"))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Devices: %s", err)
+	}
+	return devices, nil
+}
+
+// flattenDevice converts a device to the attributes of `getDeviceDataSourceSchema`
+func flattenDevice(device Device) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":                    device.Uuid,
+		"device_name":             device.DeviceName,
+		"last_vpn_event":          device.LastVpnEvent,
+		"last_connectivity_event": device.LastConnectivityEvent,
+		"ip_address":              device.IpAddress,
+		"mac_addresses":           strings.Split(device.MacAddresses, " "),
+		"created":                 device.Created,
+		"latitude":                device.Latitude,
+		"longitude":               device.Longitude,
+		"custom_longitude":        device.CustomLongitude,
+		"custom_latitude":         device.CustomerLatitude,
+		"os_version":              device.OsVersion,
+		"supervisor_version":      device.SupervisorVersion,
+		"public_ip_address":       device.PublicAddress,
+		"description":             device.Description,
+		"fleet_id":                device.FleetId.ID,
+		"running_release_id":      device.RunningReleaseId.ID,
+		"device_type_id":          device.DeviceTypeId.ID,
+		"pinned_release_id":       device.PinnedReleaseId.ID,
+		"is_online":               device.IsOnline,
+	}
 }
 
 func dataSourceDevice() *schema.Resource {
@@ -175,57 +228,426 @@ func dataSourceDevice() *schema.Resource {
 	}
 }
 
-func GetDeviceDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
-	device, err := FetchDevice(d.Get("uuid").(string))
+func GetDeviceDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	device, err := FetchDevice(client, d.Get("uuid").(string))
 	if err != nil {
 		return err
 	}
 
+	deviceAttributes := flattenDevice(*device)
 	for dataSourceAttribute := range getDeviceDataSourceSchema() {
-		switch dataSourceAttribute {
+		value, ok := deviceAttributes[dataSourceAttribute]
+		if !ok {
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+		}
+		_ = d.Set(dataSourceAttribute, value)
+	}
+
+	d.SetId(GetDeviceId(device.Uuid))
+	return nil
+}
+
+// getDeviceAttributesSchema the attributes of `getDeviceDataSourceSchema` as computed-only attributes,
+// for the elements of `balena_devices`
+func getDeviceAttributesSchema() map[string]*schema.Schema {
+	s := make(map[string]*schema.Schema)
+	for attribute, attributeSchema := range getDeviceDataSourceSchema() {
+		s[attribute] = &schema.Schema{
+			Type:        attributeSchema.Type,
+			Elem:        attributeSchema.Elem,
+			Computed:    true,
+			Description: attributeSchema.Description,
+		}
+	}
+	return s
+}
+
+func getDevicesDataSourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"fleet_id": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ExactlyOneOf: []string{"fleet_id", "organization_id"},
+			Description:  "List the devices of the fleet with this ID. Exactly one of `fleet_id` and `organization_id` must be set.",
+		},
+		"organization_id": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ExactlyOneOf: []string{"fleet_id", "organization_id"},
+			Description:  "List the devices of all fleets of the organization with this ID.",
+		},
+		"is_online": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "When set, only list online (`true`) or offline (`false`) devices.",
+		},
+		"tag_key": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Only list devices with a tag with this key.",
+		},
+		"tag_value": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			RequiredWith: []string{"tag_key"},
+			Description:  "Only list devices whose tag `tag_key` has this value.",
+		},
+		"os_version": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Only list devices running this OS version, e.g. `balenaOS 5.3.10`.",
+		},
+		"supervisor_version": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Only list devices running this supervisor version.",
+		},
+		"running_release_id": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "Only list devices running the release with this ID.",
+		},
+		"device_type_id": {
+			Type:          schema.TypeInt,
+			Optional:      true,
+			ConflictsWith: []string{"device_type"},
+			Description:   "Only list devices of the device type with this ID.",
+		},
+		"device_type": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ConflictsWith: []string{"device_type_id"},
+			Description:   "Only list devices of the device type with this slug, e.g. `raspberrypi4-64`.",
+		},
+		"devices": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The devices matching all filters, ordered by `device_name`.",
+			Elem: &schema.Resource{
+				Schema: getDeviceAttributesSchema(),
+			},
+		},
+	}
+}
+
+func dataSourceDevices() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetDevicesDataSource,
+		Schema:      getDevicesDataSourceSchema(),
+		Description: "List the devices of a fleet or organization, optionally filtered by status, tag, versions, release and device type.",
+	}
+}
+
+func GetDevicesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	var filters []api.Filter
+	if fleetId := d.Get("fleet_id").(int); fleetId != 0 {
+		filters = append(filters, api.Eq("belongs_to__application", fleetId))
+	}
+	if organizationId := d.Get("organization_id").(int); organizationId != 0 {
+		filters = append(filters, api.Eq("belongs_to__application/organization", organizationId))
+	}
+	if isOnline := d.GetRawConfig().GetAttr("is_online"); !isOnline.IsNull() {
+		filters = append(filters, api.Eq("is_online", isOnline.True()))
+	}
+	if tagKey := d.Get("tag_key").(string); tagKey != "" {
+		tagFilter := api.Eq("t/tag_key", tagKey)
+		if tagValue := d.Get("tag_value").(string); tagValue != "" {
+			tagFilter = api.And(tagFilter, api.Eq("t/value", tagValue))
+		}
+		filters = append(filters, api.Any("device_tag", "t", tagFilter))
+	}
+	if osVersion := d.Get("os_version").(string); osVersion != "" {
+		filters = append(filters, api.Eq("os_version", osVersion))
+	}
+	if supervisorVersion := d.Get("supervisor_version").(string); supervisorVersion != "" {
+		filters = append(filters, api.Eq("supervisor_version", supervisorVersion))
+	}
+	if runningReleaseId := d.Get("running_release_id").(int); runningReleaseId != 0 {
+		filters = append(filters, api.Eq("is_running__release", runningReleaseId))
+	}
+	if deviceTypeId := d.Get("device_type_id").(int); deviceTypeId != 0 {
+		filters = append(filters, api.Eq("is_of__device_type", deviceTypeId))
+	}
+	if deviceType := d.Get("device_type").(string); deviceType != "" {
+		filters = append(filters, api.Eq("is_of__device_type/slug", deviceType))
+	}
+	filter := api.And(filters...)
+
+	devices, err := DescribeDevices(client, filter)
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, device := range devices {
+		response = append(response, flattenDevice(device))
+	}
+	if err := d.Set("devices", response); err != nil {
+		return diag.Errorf("failed to set devices: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("devices:%d", schema.HashString(filter.String())))
+	return nil
+}
+
+func resourceDevice() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceDeviceCreate,
+		ReadContext:   ResourceDeviceRead,
+		UpdateContext: ResourceDeviceUpdate,
+		DeleteContext: ResourceDeviceDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceDeviceImport,
+		},
+		Schema:      getDeviceResourceSchema(),
+		Description: "Pre-register a device in a fleet and manage its name, location, pinned release and fleet. Destroying the resource deregisters the device.",
+	}
+}
+
+func getDeviceResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"uuid": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: "The UUID to register the device with. A random UUID is generated when not set.",
+		},
+		"fleet_id": {
+			Type:        schema.TypeInt,
+			Required:    true,
+			Description: "The ID of the fleet the device belongs to. Changing this moves the device to another fleet.",
+		},
+		"device_type_id": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: "The ID of the device type. Defaults to the device type of the fleet.",
+		},
+		"device_name": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			Description: "The display name of the device. Balena generates one when not set.",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The description of the device, representing the `note` field of the API.",
+		},
+		"custom_latitude": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The custom latitude of the device, overriding the latitude derived from its IP address.",
+		},
+		"custom_longitude": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The custom longitude of the device, overriding the longitude derived from its IP address.",
+		},
+		"pinned_release_id": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "The ID of the release to pin the device to. When not set, the device follows the release of its fleet.",
+		},
+		"device_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The numeric ID of the device.",
+		},
+		"running_release_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the running release of the device.",
+		},
+		"os_version": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The OS version on the device.",
+		},
+		"supervisor_version": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The supervisor version on the device.",
+		},
+		"created": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The time the device was created, represented as a string in ISO-Format.",
+		},
+	}
+}
+
+func ResourceDeviceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+
+	deviceUuid := d.Get("uuid").(string)
+	if deviceUuid == "" {
+		deviceUuid = strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+
+	deviceTypeId := d.Get("device_type_id").(int)
+	if deviceTypeId == 0 {
+		fleet, err := FetchFleet(client, "", fleetId)
+		if err != nil {
+			return err
+		}
+		deviceTypeId = fleet.DeviceType.ID
+	}
+
+	body := map[string]interface{}{
+		"uuid":                    deviceUuid,
+		"belongs_to__application": fleetId,
+		"is_of__device_type":      deviceTypeId,
+	}
+	if v, ok := d.GetOk("device_name"); ok {
+		body["device_name"] = v.(string)
+	}
+
+	if _, err := api.Create[Device](client, api.Device, body); err != nil {
+		return diag.Errorf("error registering device: %s", err)
+	}
+
+	d.SetId(GetDeviceId(deviceUuid))
+	_ = d.Set("uuid", deviceUuid)
+
+	// The remaining attributes cannot be set at registration
+	body = map[string]interface{}{}
+	for _, resourceAttribute := range []string{"description", "custom_latitude", "custom_longitude", "pinned_release_id"} {
+		if _, ok := d.GetOk(resourceAttribute); ok {
+			body[deviceResourceFields[resourceAttribute]] = deviceFieldValue(d, resourceAttribute)
+		}
+	}
+	if len(body) > 0 {
+		if err := UpdateDevice(client, deviceUuid, body); err != nil {
+			return err
+		}
+	}
+
+	return ResourceDeviceRead(ctx, d, m)
+}
+
+func ResourceDeviceRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	deviceUuid := strings.TrimPrefix(d.Id(), "device:")
+
+	device, err := LookupDevice(client, deviceUuid)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		return removeFromState(d, "device %s no longer exists", deviceUuid)
+	}
+
+	for resourceAttribute := range getDeviceResourceSchema() {
+		switch resourceAttribute {
 		case "uuid":
-			log.Print(device.Uuid)
+			_ = d.Set("uuid", device.Uuid)
+		case "fleet_id":
+			_ = d.Set("fleet_id", device.FleetId.ID)
+		case "device_type_id":
+			_ = d.Set("device_type_id", device.DeviceTypeId.ID)
 		case "device_name":
 			_ = d.Set("device_name", device.DeviceName)
-		case "last_vpn_event":
-			_ = d.Set("last_vpn_event", device.LastVpnEvent)
-		case "last_connectivity_event":
-			_ = d.Set("last_connectivity_event", device.LastConnectivityEvent)
-		case "ip_address":
-			_ = d.Set("ip_address", device.IpAddress)
-		case "mac_addresses":
-			_ = d.Set("mac_addresses", strings.Split(device.MacAddresses, " "))
-		case "created":
-			_ = d.Set("created", device.Created)
-		case "latitude":
-			_ = d.Set("latitude", device.Latitude)
-		case "longitude":
-			_ = d.Set("longitude", device.Longitude)
-		case "custom_longitude":
-			_ = d.Set("custom_longitude", device.CustomLongitude)
+		case "description":
+			_ = d.Set("description", device.Description)
 		case "custom_latitude":
 			_ = d.Set("custom_latitude", device.CustomerLatitude)
+		case "custom_longitude":
+			_ = d.Set("custom_longitude", device.CustomLongitude)
+		case "pinned_release_id":
+			_ = d.Set("pinned_release_id", device.PinnedReleaseId.ID)
+		case "device_id":
+			_ = d.Set("device_id", device.Id)
+		case "running_release_id":
+			_ = d.Set("running_release_id", device.RunningReleaseId.ID)
 		case "os_version":
 			_ = d.Set("os_version", device.OsVersion)
 		case "supervisor_version":
 			_ = d.Set("supervisor_version", device.SupervisorVersion)
-		case "public_ip_address":
-			_ = d.Set("public_ip_address", device.PublicAddress)
-		case "description":
-			_ = d.Set("description", device.Description)
-		case "fleet_id":
-			_ = d.Set("fleet_id", device.FleetId.ID)
-		case "running_release_id":
-			_ = d.Set("running_release_id", device.RunningReleaseId)
-		case "device_type_id":
-			_ = d.Set("device_type_id", device.DeviceTypeId.ID)
-		case "pinned_release_id":
-			_ = d.Set("pinned_release_id", device.PinnedReleaseId)
+		case "created":
+			_ = d.Set("created", device.Created)
 		default:
-			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+			return diag.Errorf("unhandled resource attribute: %s", resourceAttribute)
+		}
+	}
+
+	return nil
+}
+
+// deviceResourceFields maps the mutable resource attributes to the fields of the device model
+var deviceResourceFields = map[string]string{
+	"fleet_id":          "belongs_to__application",
+	"device_name":       "device_name",
+	"description":       "note",
+	"custom_latitude":   "custom_latitude",
+	"custom_longitude":  "custom_longitude",
+	"pinned_release_id": "is_pinned_on__release",
+}
+
+// deviceFieldValue converts a resource attribute to the value sent to the API,
+// unpinning the device when no release is configured
+func deviceFieldValue(d *schema.ResourceData, resourceAttribute string) interface{} {
+	if resourceAttribute == "pinned_release_id" && d.Get(resourceAttribute).(int) == 0 {
+		return nil
+	}
+	return d.Get(resourceAttribute)
+}
+
+func ResourceDeviceUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	body := map[string]interface{}{}
+	for resourceAttribute, field := range deviceResourceFields {
+		if d.HasChange(resourceAttribute) {
+			body[field] = deviceFieldValue(d, resourceAttribute)
 		}
 	}
 
+	if len(body) > 0 {
+		if err := UpdateDevice(client, d.Get("uuid").(string), body); err != nil {
+			return err
+		}
+	}
+
+	return ResourceDeviceRead(ctx, d, m)
+}
+
+func ResourceDeviceDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	if err := client.Delete(api.Device.Key("uuid", d.Get("uuid").(string))); err != nil && !api.IsNotFound(err) {
+		return diag.Errorf("error deregistering device: %s", err)
+	}
+
+	return nil
+}
+
+// ResourceDeviceImport accepts the resource ID (`device:<uuid>`) or a device UUID
+func ResourceDeviceImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	device, diags := FetchDevice(client, strings.TrimPrefix(d.Id(), "device:"))
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+
 	d.SetId(GetDeviceId(device.Uuid))
+	return []*schema.ResourceData{d}, nil
+}
+
+func UpdateDevice(client *api.Client, deviceUuid string, body map[string]interface{}) diag.Diagnostics {
+	if err := client.Patch(api.Device.Key("uuid", deviceUuid), body); err != nil {
+		return diag.Errorf("error updating device: %s", err)
+	}
+
 	return nil
 }
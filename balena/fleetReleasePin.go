@@ -0,0 +1,213 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"log"
+	"strings"
+)
+
+func GetFleetReleasePinId(fleetId int) string {
+	return fmt.Sprintf("fleet-release-pin:%d", fleetId)
+}
+
+func resourceFleetReleasePin() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceFleetReleasePinCreate,
+		ReadContext:   ResourceFleetReleasePinRead,
+		UpdateContext: ResourceFleetReleasePinUpdate,
+		DeleteContext: ResourceFleetReleasePinDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceFleetReleasePinImport,
+		},
+		Description: "Manage the release a fleet should be running. Pin the fleet to a release by its `release_id`, `commit` or `semver`, " +
+			"or leave all three unset to track the latest release. Destroying the resource makes the fleet track the latest release again. " +
+			"Leave `track_latest_release` of the `balena_fleet` unset when its release is pinned by this resource, as the two would undo each other.",
+		Schema: map[string]*schema.Schema{
+			"fleet_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the fleet.",
+			},
+			"release_id": {
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ConflictsWith: []string{"commit", "semver"},
+				Description:   "The ID of the release to pin the fleet to.",
+			},
+			"commit": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"release_id", "semver"},
+				Description:   "The commit hash, or a prefix of it, of the release to pin the fleet to.",
+			},
+			"semver": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"release_id", "commit"},
+				Description:   "The semantic version of the final release to pin the fleet to.",
+			},
+			"pinned_release_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the release the fleet is pinned to, or `0` when the fleet tracks the latest release.",
+			},
+			"track_latest_release": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the fleet tracks the latest release.",
+			},
+		},
+	}
+}
+
+// resolveFleetReleasePin resolves the configured `release_id`, `commit` or `semver` to a release,
+// returning nil when none is configured and the fleet should track the latest release
+func resolveFleetReleasePin(client *api.Client, d *schema.ResourceData) (*Release, diag.Diagnostics) {
+	releaseId := d.Get("release_id").(int)
+	commit := d.Get("commit").(string)
+	semver := d.Get("semver").(string)
+
+	if releaseId == 0 && commit == "" && semver == "" {
+		return nil, nil
+	}
+	return FindRelease(client, d.Get("fleet_id").(int), releaseId, commit, semver, "")
+}
+
+// applyFleetReleasePin resolves the configured release and patches the fleet to run it, or to track
+// the latest release when no release is configured. It returns the ID of the pinned release, or 0
+func applyFleetReleasePin(client *api.Client, d *schema.ResourceData) (int, diag.Diagnostics) {
+	fleetId := d.Get("fleet_id").(int)
+
+	release, err := resolveFleetReleasePin(client, d)
+	if err != nil {
+		return 0, err
+	}
+	if release == nil {
+		return 0, UpdateFleet(client, fleetId, map[string]interface{}{
+			"should_track_latest_release": true,
+		})
+	}
+
+	if release.Status != "success" {
+		return 0, diag.Errorf("the release %d has the status %s, only successful releases can be pinned", release.Id, release.Status)
+	}
+	if release.IsInvalidated {
+		return 0, diag.Errorf("the release %d is invalidated and cannot be pinned", release.Id)
+	}
+
+	return release.Id, UpdateFleet(client, fleetId, map[string]interface{}{
+		"should_be_running__release":  release.Id,
+		"should_track_latest_release": false,
+	})
+}
+
+func ResourceFleetReleasePinCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	releaseId, err := applyFleetReleasePin(client, d)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetFleetReleasePinId(d.Get("fleet_id").(int)))
+	return readFleetReleasePin(client, d, releaseId)
+}
+
+// ResourceFleetReleasePinRead compares the release the fleet runs with the release the `release_id`, `commit`
+// or `semver` in state, i.e. as last applied, resolve to now, rather than with `pinned_release_id`, as `commit`
+// and `semver` may resolve to a newer release since
+func ResourceFleetReleasePinRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	configuredReleaseId := 0
+	release, err := resolveFleetReleasePin(client, d)
+	if err != nil {
+		// The configured release no longer resolves, e.g. because it was deleted: the fleet cannot be running it
+		log.Printf("[WARN] the configured release of the fleet %d cannot be resolved: %v", d.Get("fleet_id").(int), err)
+		configuredReleaseId = -1
+	} else if release != nil {
+		configuredReleaseId = release.Id
+	}
+
+	return readFleetReleasePin(client, d, configuredReleaseId)
+}
+
+// readFleetReleasePin sets the state from the fleet. configuredReleaseId is the release the configuration
+// resolves to, or 0 to track the latest release; a fleet running another release was re-pinned outside of Terraform
+func readFleetReleasePin(client *api.Client, d *schema.ResourceData, configuredReleaseId int) diag.Diagnostics {
+	fleetId := d.Get("fleet_id").(int)
+
+	fleet, err := LookupFleet(client, "", fleetId)
+	if err != nil {
+		return err
+	}
+	if fleet == nil {
+		return removeFromState(d, "the fleet %d of the release pin no longer exists", fleetId)
+	}
+
+	pinnedReleaseId := 0
+	if !fleet.TrackLatestRelease {
+		pinnedReleaseId = fleet.ReleaseId.ID
+	}
+
+	// The fleet was pinned or unpinned outside of Terraform: reflect the actual release in
+	// the configurable attributes so that Terraform plans to restore the configured one
+	if pinnedReleaseId != configuredReleaseId {
+		_ = d.Set("release_id", pinnedReleaseId)
+		_ = d.Set("commit", "")
+		_ = d.Set("semver", "")
+	}
+
+	_ = d.Set("pinned_release_id", pinnedReleaseId)
+	_ = d.Set("track_latest_release", fleet.TrackLatestRelease)
+	return nil
+}
+
+func ResourceFleetReleasePinUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	releaseId, err := applyFleetReleasePin(client, d)
+	if err != nil {
+		return err
+	}
+
+	return readFleetReleasePin(client, d, releaseId)
+}
+
+func ResourceFleetReleasePinDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+
+	fleet, err := LookupFleet(client, "", fleetId)
+	if err != nil {
+		return err
+	}
+	if fleet == nil {
+		return nil
+	}
+
+	return UpdateFleet(client, fleetId, map[string]interface{}{
+		"should_track_latest_release": true,
+	})
+}
+
+// ResourceFleetReleasePinImport accepts the resource ID (`fleet-release-pin:<fleet_id>`),
+// a fleet ID or a fleet slug
+func ResourceFleetReleasePinImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	fleetId, err := resolveFleetImportId(client, strings.TrimPrefix(d.Id(), "fleet-release-pin:"))
+	if err != nil {
+		return nil, err
+	}
+
+	_ = d.Set("fleet_id", fleetId)
+	d.SetId(GetFleetReleasePinId(fleetId))
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,262 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strings"
+)
+
+type FleetTag struct {
+	Id    int    `json:"id"`
+	Key   string `json:"tag_key"`
+	Value string `json:"value"`
+}
+
+func GetFleetTagsId(fleetId int) string {
+	return fmt.Sprintf("fleet-tags:%d", fleetId)
+}
+
+func GetFleetTagId(fleetId int, tagKey string) string {
+	return fmt.Sprintf("fleet-tag:%d:%s", fleetId, tagKey)
+}
+
+// getFleetKeySchema the attributes identifying a fleet by either its ID or its slug
+func getFleetKeySchema(forceNew bool) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"fleet_id": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Computed:     true,
+			ForceNew:     forceNew,
+			ExactlyOneOf: []string{"fleet_id", "slug"},
+			Description:  "The ID of the fleet. Exactly one of `fleet_id` and `slug` must be set.",
+		},
+		"slug": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			ForceNew:     forceNew,
+			ExactlyOneOf: []string{"fleet_id", "slug"},
+			Description:  "The slug of the fleet.",
+		},
+	}
+}
+
+func dataSourceFleetTags() *schema.Resource {
+	s := getFleetKeySchema(false)
+	s["tags"] = &schema.Schema{
+		Type:        schema.TypeMap,
+		Computed:    true,
+		Description: "A key-value pair of fleet tags.",
+	}
+
+	return &schema.Resource{
+		ReadContext: GetFleetTagsDataSource,
+		Schema:      s,
+		Description: "Get all tags of a fleet given its `fleet_id` or `slug`.",
+	}
+}
+
+func resourceFleetTag() *schema.Resource {
+	s := getFleetKeySchema(true)
+	s["tag_key"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Required:    true,
+		ForceNew:    true,
+		Description: "The key of the tag.",
+	}
+	s["value"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Required:    true,
+		Description: "The value of the tag.",
+	}
+
+	return &schema.Resource{
+		CreateContext: ResourceFleetTagCreate,
+		ReadContext:   ResourceFleetTagRead,
+		UpdateContext: ResourceFleetTagUpdate,
+		DeleteContext: ResourceFleetTagDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceFleetTagImport,
+		},
+		Schema:      s,
+		Description: "Manage a single tag of a fleet.",
+	}
+}
+
+// resolveFleetKey fetches the fleet identified by either the `fleet_id` or the `slug` attribute
+func resolveFleetKey(client *api.Client, d *schema.ResourceData) (*Fleet, diag.Diagnostics) {
+	if slug := d.Get("slug").(string); slug != "" && d.Get("fleet_id").(int) == 0 {
+		return FetchFleet(client, slug, -1)
+	}
+	return FetchFleet(client, "", d.Get("fleet_id").(int))
+}
+
+func FetchFleetTags(client *api.Client, fleetId int) ([]FleetTag, diag.Diagnostics) {
+	tags, err := api.Get[FleetTag](client, api.ApplicationTag.All().Filter(api.Eq("application", fleetId)))
+	if err != nil {
+		return nil, diag.Errorf("retrieving fleet tags for %d failed with the error %s", fleetId, err)
+	}
+	return tags, nil
+}
+
+func existingFleetTags(tags []FleetTag) map[string]existingTag {
+	existing := make(map[string]existingTag)
+	for _, tag := range tags {
+		existing[tag.Key] = existingTag{id: tag.Id, value: tag.Value}
+	}
+	return existing
+}
+
+func GetFleetTagsDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleet, err := resolveFleetKey(client, d)
+	if err != nil {
+		return err
+	}
+
+	tags, err := FetchFleetTags(client, fleet.FleetID)
+	if err != nil {
+		return err
+	}
+
+	newTags := map[string]string{}
+	for _, fleetTag := range tags {
+		newTags[fleetTag.Key] = fleetTag.Value
+	}
+
+	_ = d.Set("fleet_id", fleet.FleetID)
+	_ = d.Set("slug", fleet.Slug)
+	_ = d.Set("tags", newTags)
+
+	d.SetId(GetFleetTagsId(fleet.FleetID))
+	return nil
+}
+
+func ResourceFleetTagCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	tagKey := d.Get("tag_key").(string)
+
+	fleet, err := resolveFleetKey(client, d)
+	if err != nil {
+		return err
+	}
+
+	tags, err := FetchFleetTags(client, fleet.FleetID)
+	if err != nil {
+		return err
+	}
+	if _, ok := existingFleetTags(tags)[tagKey]; ok {
+		return diag.Errorf("tag %s already exists on the fleet %s", tagKey, fleet.Slug)
+	}
+
+	if err := CreateTag(client, api.ApplicationTag, "application", fleet.FleetID, tagKey, d.Get("value").(string)); err != nil {
+		return err
+	}
+
+	_ = d.Set("fleet_id", fleet.FleetID)
+	_ = d.Set("slug", fleet.Slug)
+	d.SetId(GetFleetTagId(fleet.FleetID, tagKey))
+	return ResourceFleetTagRead(ctx, d, m)
+}
+
+// ResourceFleetTagRead removes a tag deleted outside of Terraform from state
+func ResourceFleetTagRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	tagKey := d.Get("tag_key").(string)
+
+	tags, err := FetchFleetTags(client, fleetId)
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingFleetTags(tags)[tagKey]
+	if !ok {
+		return removeFromState(d, "tag %s no longer exists on the fleet %d", tagKey, fleetId)
+	}
+
+	_ = d.Set("value", tag.value)
+	return nil
+}
+
+func ResourceFleetTagUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	tagKey := d.Get("tag_key").(string)
+
+	tags, err := FetchFleetTags(client, fleetId)
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingFleetTags(tags)[tagKey]
+	if !ok {
+		return diag.Errorf("no tag %s configured for the fleet %d", tagKey, fleetId)
+	}
+
+	if err := UpdateTag(client, api.ApplicationTag, tag.id, d.Get("value").(string)); err != nil {
+		return err
+	}
+	return ResourceFleetTagRead(ctx, d, m)
+}
+
+func ResourceFleetTagDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	tags, err := FetchFleetTags(client, d.Get("fleet_id").(int))
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingFleetTags(tags)[d.Get("tag_key").(string)]
+	if !ok {
+		// Already deleted outside of Terraform
+		return nil
+	}
+
+	return DeleteTag(client, api.ApplicationTag, tag.id)
+}
+
+// ResourceFleetTagImport accepts the resource ID (`fleet-tag:<fleet_id>:<tag_key>`),
+// as well as `<fleet_id>/<tag_key>` and `<fleet slug>/<tag_key>`
+func ResourceFleetTagImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	var fleetReference, tagKey string
+	if strings.HasPrefix(d.Id(), "fleet-tag:") {
+		parts := strings.SplitN(strings.TrimPrefix(d.Id(), "fleet-tag:"), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid import ID %q, expected fleet-tag:<fleet_id>:<tag_key>", d.Id())
+		}
+		fleetReference, tagKey = parts[0], parts[1]
+	} else {
+		separator := strings.LastIndex(d.Id(), "/")
+		if separator == -1 {
+			return nil, fmt.Errorf("invalid import ID %q, expected <fleet_id or slug>/<tag_key>", d.Id())
+		}
+		fleetReference, tagKey = d.Id()[:separator], d.Id()[separator+1:]
+	}
+
+	fleetId, err := resolveFleetImportId(client, fleetReference)
+	if err != nil {
+		return nil, err
+	}
+	fleet, diags := FetchFleet(client, "", fleetId)
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+
+	_ = d.Set("fleet_id", fleet.FleetID)
+	_ = d.Set("slug", fleet.Slug)
+	_ = d.Set("tag_key", tagKey)
+	d.SetId(GetFleetTagId(fleet.FleetID, tagKey))
+	return []*schema.ResourceData{d}, nil
+}
@@ -2,10 +2,11 @@ package balena
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strings"
 )
 
 type FleetVariable struct {
@@ -15,10 +16,6 @@ type FleetVariable struct {
 	Created string `json:"created_at"`
 }
 
-type FleetVariablesResponse struct {
-	FleetVariables []FleetVariable `json:"d"`
-}
-
 func GetSingularFleetVariableId(fleetId int, variableName string) string {
 	return fmt.Sprintf("fleet-variable:%d:%s", fleetId, variableName)
 }
@@ -79,27 +76,20 @@ func getFleetVariablesDataSourceSchema() map[string]*schema.Schema {
 	}
 }
 
-func DescribeFleetVariables(fleetId int) ([]FleetVariable, diag.Diagnostics) {
-	endpoint := fmt.Sprintf("/v7/application_environment_variable?$filter=%s", fmt.Sprintf("application eq %d", fleetId))
-	res, err := client.client.R().Get(endpoint)
+func DescribeFleetVariables(client *api.Client, fleetId int) ([]FleetVariable, diag.Diagnostics) {
+	fleetVariables, err := api.Get[FleetVariable](client, api.ApplicationEnvironmentVariable.All().
+		Filter(api.Eq("application", fleetId)))
 	if err != nil {
-		return nil, diag.FromErr(err)
-	}
-	if !is200Level(res.StatusCode()) {
-		return nil, diag.FromErr(fmt.Errorf("error retrieving Fleet Variables: %s", res.Status()))
-	}
-
-	var fleetVariables FleetVariablesResponse
-	if err := json.Unmarshal(res.Body(), &fleetVariables); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("failed to unmarshal response from Balena fleet variables API: %w", err))
+		return nil, diag.Errorf("error retrieving Fleet Variables: %s", err)
 	}
-
-	return fleetVariables.FleetVariables, nil
+	return fleetVariables, nil
 }
 
-func GetFleetVariablesDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func GetFleetVariablesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	fleetId := d.Get("fleet_id").(int)
-	variables, err := DescribeFleetVariables(d.Get("fleet_id").(int))
+	variables, err := DescribeFleetVariables(client, d.Get("fleet_id").(int))
 	if err != nil {
 		return err
 	}
@@ -124,12 +114,14 @@ func GetFleetVariablesDataSource(_ context.Context, d *schema.ResourceData, _ in
 	return nil
 }
 
-func GetFleetVariableDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func GetFleetVariableDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	fleetId := d.Get("fleet_id").(int)
 	variableName := d.Get("variable_name").(string)
 	var variableValue string
 	found := false
-	variables, err := DescribeFleetVariables(fleetId)
+	variables, err := DescribeFleetVariables(client, fleetId)
 	if err != nil {
 		return err
 	}
@@ -175,8 +167,11 @@ func privateFleetVariableResource(sensitive bool) *schema.Resource {
 	return &schema.Resource{
 		CreateContext: ResourceFleetVariableCreate,
 		UpdateContext: ResourceFleetVariableUpdate,
-		ReadContext:   GetFleetVariableDataSource,
+		ReadContext:   ResourceFleetVariableRead,
 		DeleteContext: ResourceFleetVariableDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceFleetVariableImport,
+		},
 		Schema: map[string]*schema.Schema{
 			"fleet_id": {
 				Type:     schema.TypeInt,
@@ -184,9 +179,10 @@ func privateFleetVariableResource(sensitive bool) *schema.Resource {
 				ForceNew: true,
 			},
 			"variable_name": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateEnvironmentVariableName,
 			},
 			"value": {
 				Type:      schema.TypeString,
@@ -197,12 +193,14 @@ func privateFleetVariableResource(sensitive bool) *schema.Resource {
 	}
 }
 
-func ResourceFleetVariableCreate(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func ResourceFleetVariableCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	fleetId := d.Get("fleet_id").(int)
 	variableName := d.Get("variable_name").(string)
 	variableValue := d.Get("value").(string)
 
-	fleetVariables, err := DescribeFleetVariables(fleetId)
+	fleetVariables, err := DescribeFleetVariables(client, fleetId)
 	if err != nil {
 		return err
 	}
@@ -213,7 +211,7 @@ func ResourceFleetVariableCreate(_ context.Context, d *schema.ResourceData, _ in
 		}
 	}
 
-	err = CreateFleetVariable(fleetId, variableName, variableValue)
+	err = CreateFleetVariable(client, fleetId, variableName, variableValue)
 	if err != nil {
 		return err
 	}
@@ -222,108 +220,135 @@ func ResourceFleetVariableCreate(_ context.Context, d *schema.ResourceData, _ in
 	return nil
 }
 
-func ResourceFleetVariableUpdate(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+// LookupFleetVariable finds a single variable of a fleet, returning nil without an error
+// when the variable does not exist
+func LookupFleetVariable(client *api.Client, fleetId int, variableName string) (*FleetVariable, diag.Diagnostics) {
+	return lookupObject(func() ([]FleetVariable, diag.Diagnostics) {
+		return DescribeFleetVariables(client, fleetId)
+	}, func(variable FleetVariable) bool {
+		return variable.Name == variableName
+	})
+}
+
+// ResourceFleetVariableRead differs from the data source in that a variable deleted outside of
+// Terraform is removed from state instead of failing, so that Terraform proposes to recreate it
+func ResourceFleetVariableRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	fleetId := d.Get("fleet_id").(int)
 	variableName := d.Get("variable_name").(string)
-	variableValue := d.Get("value").(string)
-	found := false
 
-	fleetVariables, err := DescribeFleetVariables(fleetId)
+	variable, err := LookupFleetVariable(client, fleetId, variableName)
 	if err != nil {
 		return err
 	}
-
-	for _, variable := range fleetVariables {
-		if variableName == variable.Name {
-			found = true
-			err = UpdateFleetVariable(variable.Id, variableValue)
-			if err != nil {
-				return err
-			}
-			break
-		}
+	if variable == nil {
+		return removeFromState(d, "variable %s no longer exists for the fleet %d", variableName, fleetId)
 	}
 
-	if !found {
+	_ = d.Set("fleet_id", fleetId)
+	_ = d.Set("variable_name", variable.Name)
+	_ = d.Set("value", variable.Value)
+	return nil
+}
+
+func ResourceFleetVariableUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	variableName := d.Get("variable_name").(string)
+	variableValue := d.Get("value").(string)
+
+	variable, err := LookupFleetVariable(client, fleetId, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
 		return diag.Errorf("no variable %s configured for the fleet %d", variableName, fleetId)
 	}
 
-	return nil
+	return UpdateFleetVariable(client, variable.Id, variableValue)
 }
 
-func ResourceFleetVariableDelete(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func ResourceFleetVariableDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	fleetId := d.Get("fleet_id").(int)
 	variableName := d.Get("variable_name").(string)
-	found := false
 
-	fleetVariables, err := DescribeFleetVariables(fleetId)
+	variable, err := LookupFleetVariable(client, fleetId, variableName)
 	if err != nil {
 		return err
 	}
+	if variable == nil {
+		// Already deleted outside of Terraform
+		return nil
+	}
 
-	for _, variable := range fleetVariables {
-		if variableName == variable.Name {
-			found = true
-			err = DeleteFleetVariable(variable.Id)
-			if err != nil {
-				return err
-			}
-			break
+	return DeleteFleetVariable(client, variable.Id)
+}
+
+// ResourceFleetVariableImport accepts the resource ID (`fleet-variable:<fleet_id>:<variable_name>`),
+// as well as `<fleet_id>/<variable_name>` and `<fleet slug>/<variable_name>`
+func ResourceFleetVariableImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	var fleetReference, variableName string
+	if strings.HasPrefix(d.Id(), "fleet-variable:") {
+		parts := strings.SplitN(strings.TrimPrefix(d.Id(), "fleet-variable:"), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid import ID %q, expected fleet-variable:<fleet_id>:<variable_name>", d.Id())
 		}
+		fleetReference, variableName = parts[0], parts[1]
+	} else {
+		separator := strings.LastIndex(d.Id(), "/")
+		if separator == -1 {
+			return nil, fmt.Errorf("invalid import ID %q, expected <fleet_id or slug>/<variable_name>", d.Id())
+		}
+		fleetReference, variableName = d.Id()[:separator], d.Id()[separator+1:]
 	}
 
-	if !found {
-		return diag.Errorf("no variable %s configured for the fleet %d", variableName, fleetId)
+	fleetId, err := resolveFleetImportId(client, fleetReference)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-
+	_ = d.Set("fleet_id", fleetId)
+	_ = d.Set("variable_name", variableName)
+	d.SetId(GetSingularFleetVariableId(fleetId, variableName))
+	return []*schema.ResourceData{d}, nil
 }
 
-func CreateFleetVariable(fleetId int, variableName string, variableValue string) diag.Diagnostics {
-	res, err := client.client.R().
-		SetBody(map[string]interface{}{
+func CreateFleetVariable(client *api.Client, fleetId int, variableName string, variableValue string) diag.Diagnostics {
+	err := client.RetryCreate(func() error {
+		_, err := api.Create[FleetVariable](client, api.ApplicationEnvironmentVariable, map[string]interface{}{
 			"application": fleetId,
 			"name":        variableName,
 			"value":       variableValue,
-		}).
-		Post("/v7/application_environment_variable")
-
+		})
+		return err
+	}, func() (bool, error) {
+		variable, diags := LookupFleetVariable(client, fleetId, variableName)
+		return variable != nil, diagsToError(diags)
+	})
 	if err != nil {
-		return diag.FromErr(err)
-	}
-
-	if !is200Level(res.StatusCode()) {
-		return diag.FromErr(fmt.Errorf("error creating fleet variable with statuscode %d", res.StatusCode()))
+		return diag.Errorf("error creating fleet variable: %s", err)
 	}
 	return nil
 }
 
-func UpdateFleetVariable(fleetVariableId int, variableValue string) diag.Diagnostics {
-	res, err := client.client.R().
-		SetBody(map[string]interface{}{
-			"value": variableValue,
-		}).
-		Patch(fmt.Sprintf("/v7/application_environment_variable(%d)", fleetVariableId))
-	if err != nil {
-		return diag.FromErr(err)
-	}
-
-	if !is200Level(res.StatusCode()) {
-		return diag.FromErr(fmt.Errorf("error updating fleet variable with statuscode %d", res.StatusCode()))
+func UpdateFleetVariable(client *api.Client, fleetVariableId int, variableValue string) diag.Diagnostics {
+	if err := client.Patch(api.ApplicationEnvironmentVariable.ID(fleetVariableId), map[string]interface{}{
+		"value": variableValue,
+	}); err != nil {
+		return diag.Errorf("error updating fleet variable: %s", err)
 	}
-
 	return nil
 }
 
-func DeleteFleetVariable(fleetVariableId int) diag.Diagnostics {
-	res, err := client.client.R().Delete(fmt.Sprintf("/v7/application_environment_variable(%d)", fleetVariableId))
-	if err != nil {
-		return diag.FromErr(err)
-	}
-
-	if !is200Level(res.StatusCode()) {
-		return diag.FromErr(fmt.Errorf("error deleting fleet variable with statuscode %d", res.StatusCode()))
+func DeleteFleetVariable(client *api.Client, fleetVariableId int) diag.Diagnostics {
+	if err := client.Delete(api.ApplicationEnvironmentVariable.ID(fleetVariableId)); err != nil {
+		return diag.Errorf("error deleting fleet variable: %s", err)
 	}
 	return nil
 }
@@ -2,10 +2,13 @@ package balena
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type Fleet struct {
@@ -14,48 +17,64 @@ type Fleet struct {
 	Slug               string    `json:"slug"`
 	AppName            string    `json:"app_name"`
 	ReleaseId          IDWrapper `json:"should_be_running__release"`
-	DeviceType         IDWrapper `json:"device_type"`
+	DeviceType         IDWrapper `json:"is_for__device_type"`
 	TrackLatestRelease bool      `json:"should_track_latest_release"`
 	Public             bool      `json:"is_public"`
 	Host               bool      `json:"is_host"`
 	Archived           bool      `json:"is_archived"`
 	Created            string    `json:"created_at"`
 	Uuid               string    `json:"uuid"`
-}
-
-type FleetResponse struct {
-	Fleets []Fleet `json:"d"`
+	Actor              IDWrapper `json:"actor"`
 }
 
 func GetFleetId(fleetId int) string {
 	return fmt.Sprintf("fleet:%d", fleetId)
 }
 
+// ParseFleetId reverses GetFleetId, returning the numeric fleet ID
+func ParseFleetId(id string) (int, error) {
+	fleetId, err := strconv.Atoi(strings.TrimPrefix(id, "fleet:"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid fleet ID %q, expected the format fleet:<fleet_id>", id)
+	}
+	return fleetId, nil
+}
+
 func getFleetDataSourceSchema() map[string]*schema.Schema {
 	return map[string]*schema.Schema{
 		"fleet_id": {
 			Type:          schema.TypeInt,
 			Optional:      true,
 			Description:   "The ID of a fleet (also called application).",
-			ConflictsWith: []string{"slug"},
+			ConflictsWith: []string{"slug", "organization", "app_name"},
 			Default:       -1,
 		},
 		"slug": {
 			Type:          schema.TypeString,
 			Optional:      true,
 			Description:   "The slug of a fleet (also called application).",
-			ConflictsWith: []string{"fleet_id"},
+			ConflictsWith: []string{"fleet_id", "organization", "app_name"},
 			Default:       "",
 		},
 		"device_type_id": {
 			Type:        schema.TypeInt,
-			Description: "The ID of the device type configured for this fleet. These IDs can be retrieved via the `device_type` API.",
+			Description: "The ID of the device type configured for this fleet. These IDs can be retrieved via the `balena_device_type` data source.",
 			Computed:    true,
 		},
+		"organization": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Description:   "The handle of the organization of a fleet, to look the fleet up together with its `app_name`.",
+			ConflictsWith: []string{"fleet_id", "slug"},
+			RequiredWith:  []string{"app_name"},
+		},
 		"app_name": {
-			Type:        schema.TypeString,
-			Description: "The app name of the fleet -- mostly corresponds with `slug`.",
-			Computed:    true,
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			Description:   "The app name of the fleet -- mostly corresponds with `slug`. Set it together with `organization` to look the fleet up by name.",
+			ConflictsWith: []string{"fleet_id", "slug"},
+			RequiredWith:  []string{"organization"},
 		},
 		"track_latest_release": {
 			Type:        schema.TypeBool,
@@ -70,7 +89,7 @@ func getFleetDataSourceSchema() map[string]*schema.Schema {
 		"organization_id": {
 			Type:        schema.TypeInt,
 			Computed:    true,
-			Description: "The ID of the organization this fleet belongs to.",
+			Description: "The ID of the organization this fleet belongs to. See the `balena_organization` data source.",
 		},
 		"public": {
 			Type:        schema.TypeBool,
@@ -98,57 +117,388 @@ func getFleetDataSourceSchema() map[string]*schema.Schema {
 	}
 }
 
-func FetchFleet(slug string, fleetId int) (*Fleet, diag.Diagnostics) {
-	var endpoint string
+// LookupFleet retrieves a fleet by slug or ID, returning nil without an error
+// when Balena has no such fleet so that resources can drop it from state
+func LookupFleet(client *api.Client, slug string, fleetId int) (*Fleet, diag.Diagnostics) {
+	var query *api.Query
 	if slug != "" {
-		endpoint = fmt.Sprintf("/v7/application(slug='%s')", slug)
+		query = api.Application.Key("slug", slug)
 	} else {
-		endpoint = fmt.Sprintf("/v7/application(id=%d)", fleetId)
+		query = api.Application.ID(fleetId)
 	}
 
-	res, err := client.client.R().Get(endpoint)
-	if err != nil {
-		return nil, diag.FromErr(err)
+	fleets, err := api.Get[Fleet](client, query)
+	if api.IsNotFound(err) {
+		return nil, nil
 	}
-	if !is200Level(res.StatusCode()) {
-		return nil, diag.FromErr(fmt.Errorf("error retrieving Fleet: %s", res.Status()))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Fleet: %s", err)
 	}
 
-	var fleetResponse FleetResponse
+	if len(fleets) == 0 {
+		return nil, nil
+	} else if len(fleets) > 1 {
+		return nil, diag.Errorf("more than one fleet found")
+	}
 
-	err = json.Unmarshal(res.Body(), &fleetResponse)
+	return &fleets[0], nil
+}
 
-	if len(fleetResponse.Fleets) == 0 {
+func FetchFleet(client *api.Client, slug string, fleetId int) (*Fleet, diag.Diagnostics) {
+	fleet, err := LookupFleet(client, slug, fleetId)
+	if err != nil {
+		return nil, err
+	}
+	if fleet == nil {
 		return nil, diag.Errorf("no fleet found")
-	} else if len(fleetResponse.Fleets) > 1 {
+	}
+	return fleet, nil
+}
+
+// DescribeFleets lists the fleets matching an OData filter, which may be empty, ordered by name
+func DescribeFleets(client *api.Client, filter api.Filter) ([]Fleet, diag.Diagnostics) {
+	fleets, err := api.Get[Fleet](client, api.Application.All().Filter(filter).OrderBy("app_name asc"))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Fleets: %s", err)
+	}
+	return fleets, nil
+}
+
+// FetchFleetByName retrieves a fleet by the handle of its organization and its app name
+func FetchFleetByName(client *api.Client, organization string, appName string) (*Fleet, diag.Diagnostics) {
+	fleets, err := DescribeFleets(client, api.And(api.Eq("organization/handle", organization), api.Eq("app_name", appName)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fleets) == 0 {
+		return nil, diag.Errorf("no fleet %s found in the organization %s", appName, organization)
+	} else if len(fleets) > 1 {
 		return nil, diag.Errorf("more than one fleet found")
 	}
 
-	return &fleetResponse.Fleets[0], nil
+	return &fleets[0], nil
+}
+
+// flattenFleet converts a fleet to the computed attributes of `getFleetDataSourceSchema`
+func flattenFleet(fleet Fleet) map[string]interface{} {
+	return map[string]interface{}{
+		"fleet_id":             fleet.FleetID,
+		"slug":                 fleet.Slug,
+		"organization_id":      fleet.OrganizationID.ID,
+		"app_name":             fleet.AppName,
+		"device_type_id":       fleet.DeviceType.ID,
+		"public":               fleet.Public,
+		"host":                 fleet.Host,
+		"archived":             fleet.Archived,
+		"created":              fleet.Created,
+		"track_latest_release": fleet.TrackLatestRelease,
+		"release_id":           fleet.ReleaseId.ID,
+		"uuid":                 fleet.Uuid,
+	}
 }
 
 func dataSourceFleet() *schema.Resource {
 	return &schema.Resource{
 		ReadContext: GetFleetDataSource,
 		Schema:      getFleetDataSourceSchema(),
-		Description: "Retrieve information about a Fleet given its `fleet_id` or `slug`.",
+		Description: "Retrieve information about a Fleet given its `fleet_id`, its `slug`, or its `organization` and `app_name`.",
 	}
 }
 
-func GetFleetDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func GetFleetDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	organization := d.Get("organization").(string)
 	if d.Get("slug").(string) != "" && d.Get("fleet_id").(int) != -1 {
 		return diag.Errorf("only one of id or slug can be specified")
-	} else if d.Get("slug").(string) == "" && d.Get("fleet_id").(int) == -1 {
-		return diag.Errorf("either fleet_id or slug must be specified")
+	} else if d.Get("slug").(string) == "" && d.Get("fleet_id").(int) == -1 && organization == "" {
+		return diag.Errorf("either fleet_id, slug or organization and app_name must be specified")
 	}
 
-	fleet, err := FetchFleet(d.Get("slug").(string), d.Get("fleet_id").(int))
+	var fleet *Fleet
+	var err diag.Diagnostics
+	if organization != "" {
+		fleet, err = FetchFleetByName(client, organization, d.Get("app_name").(string))
+	} else {
+		fleet, err = FetchFleet(client, d.Get("slug").(string), d.Get("fleet_id").(int))
+	}
 	if err != nil {
 		return err
 	}
 
+	fleetAttributes := flattenFleet(*fleet)
 	for dataSourceAttribute := range getFleetDataSourceSchema() {
-		switch dataSourceAttribute {
+		if dataSourceAttribute == "organization" {
+			// Only used to look up the fleet, its handle is the first part of `slug`
+			continue
+		}
+		value, ok := fleetAttributes[dataSourceAttribute]
+		if !ok {
+			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+		}
+		_ = d.Set(dataSourceAttribute, value)
+	}
+
+	d.SetId(GetFleetId(fleet.FleetID))
+	return nil
+}
+
+// getFleetAttributesSchema the attributes of `getFleetDataSourceSchema` as computed-only attributes,
+// for the elements of `balena_fleets`
+func getFleetAttributesSchema() map[string]*schema.Schema {
+	s := make(map[string]*schema.Schema)
+	for attribute, attributeSchema := range getFleetDataSourceSchema() {
+		if attribute == "organization" {
+			continue
+		}
+		s[attribute] = &schema.Schema{
+			Type:        attributeSchema.Type,
+			Computed:    true,
+			Description: attributeSchema.Description,
+		}
+	}
+	return s
+}
+
+func getFleetsDataSourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"organization_id": {
+			Type:          schema.TypeInt,
+			Optional:      true,
+			ConflictsWith: []string{"organization"},
+			Description:   "Only list fleets of the organization with this ID.",
+		},
+		"organization": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ConflictsWith: []string{"organization_id"},
+			Description:   "Only list fleets of the organization with this handle.",
+		},
+		"device_type_id": {
+			Type:          schema.TypeInt,
+			Optional:      true,
+			ConflictsWith: []string{"device_type"},
+			Description:   "Only list fleets with the device type with this ID.",
+		},
+		"device_type": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ConflictsWith: []string{"device_type_id"},
+			Description:   "Only list fleets with the device type with this slug, e.g. `raspberrypi4-64`.",
+		},
+		"archived": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "When set, only list archived (`true`) or active (`false`) fleets.",
+		},
+		"public": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "When set, only list public (`true`) or private (`false`) fleets.",
+		},
+		"host": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "When set, only list host OS (`true`) or regular (`false`) fleets.",
+		},
+		"name_prefix": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Only list fleets whose `app_name` starts with this prefix.",
+		},
+		"fleets": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The fleets matching all filters, ordered by `app_name`.",
+			Elem: &schema.Resource{
+				Schema: getFleetAttributesSchema(),
+			},
+		},
+	}
+}
+
+func dataSourceFleets() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetFleetsDataSource,
+		Schema:      getFleetsDataSourceSchema(),
+		Description: "List the fleets visible to the provider, optionally filtered by organization, device type, flags and name.",
+	}
+}
+
+func GetFleetsDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	var filters []api.Filter
+	if organizationId := d.Get("organization_id").(int); organizationId != 0 {
+		filters = append(filters, api.Eq("organization", organizationId))
+	}
+	if organization := d.Get("organization").(string); organization != "" {
+		filters = append(filters, api.Eq("organization/handle", organization))
+	}
+	if deviceTypeId := d.Get("device_type_id").(int); deviceTypeId != 0 {
+		filters = append(filters, api.Eq("is_for__device_type", deviceTypeId))
+	}
+	if deviceType := d.Get("device_type").(string); deviceType != "" {
+		filters = append(filters, api.Eq("is_for__device_type/slug", deviceType))
+	}
+	for attribute, field := range map[string]string{"archived": "is_archived", "public": "is_public", "host": "is_host"} {
+		if flag := d.GetRawConfig().GetAttr(attribute); !flag.IsNull() {
+			filters = append(filters, api.Eq(field, flag.True()))
+		}
+	}
+	if namePrefix := d.Get("name_prefix").(string); namePrefix != "" {
+		filters = append(filters, api.StartsWith("app_name", namePrefix))
+	}
+	// Map iteration is random, keep the filter, and therefore the ID, stable
+	sort.Slice(filters, func(i, j int) bool { return filters[i] < filters[j] })
+	filter := api.And(filters...)
+
+	fleets, err := DescribeFleets(client, filter)
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, fleet := range fleets {
+		response = append(response, flattenFleet(fleet))
+	}
+	if err := d.Set("fleets", response); err != nil {
+		return diag.Errorf("failed to set fleets: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("fleets:%d", schema.HashString(filter.String())))
+	return nil
+}
+
+func resourceFleet() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceFleetCreate,
+		ReadContext:   ResourceFleetRead,
+		UpdateContext: ResourceFleetUpdate,
+		DeleteContext: ResourceFleetDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceFleetImport,
+		},
+		Schema:      getFleetResourceSchema(),
+		Description: "Create and manage a Fleet (also called application).",
+	}
+}
+
+func getFleetResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"app_name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			Description: "The name of the fleet. Renaming a fleet also changes its `slug`.",
+		},
+		"organization_id": {
+			Type:        schema.TypeInt,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The ID of the organization the fleet belongs to. Changing this forces a new fleet to be created.",
+		},
+		"device_type_id": {
+			Type:        schema.TypeInt,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The ID of the default device type of the fleet. Changing this forces a new fleet to be created.",
+		},
+		"host": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether the fleet hosts balenaOS host apps. Changing this forces a new fleet to be created.",
+		},
+		"public": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether the fleet is publicly available.",
+		},
+		"track_latest_release": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Computed:    true,
+			Description: "Whether the devices of the fleet should track the latest release. Leave unset when the release is managed by `balena_fleet_release_pin`.",
+		},
+		"archived": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether the fleet is archived.",
+		},
+		"fleet_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the fleet.",
+		},
+		"slug": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The slug of the fleet, in the format `<organization handle>/<app name>`.",
+		},
+		"release_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the release the fleet should be running.",
+		},
+		"created": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Timestamp of when the fleet was created, representing as an ISO-Format string.",
+		},
+		"uuid": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The UUID of the fleet.",
+		},
+	}
+}
+
+func ResourceFleetCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	body := map[string]interface{}{
+		"app_name":            d.Get("app_name").(string),
+		"organization":        d.Get("organization_id").(int),
+		"is_for__device_type": d.Get("device_type_id").(int),
+		"is_host":             d.Get("host").(bool),
+		"is_public":           d.Get("public").(bool),
+		"is_archived":         d.Get("archived").(bool),
+	}
+	if trackLatestRelease := d.GetRawConfig().GetAttr("track_latest_release"); !trackLatestRelease.IsNull() {
+		body["should_track_latest_release"] = trackLatestRelease.True()
+	}
+
+	fleet, err := api.Create[Fleet](client, api.Application, body)
+	if err != nil {
+		return diag.Errorf("error creating fleet: %s", err)
+	}
+
+	d.SetId(GetFleetId(fleet.FleetID))
+	return ResourceFleetRead(ctx, d, m)
+}
+
+func ResourceFleetRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId, parseErr := ParseFleetId(d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	fleet, err := LookupFleet(client, "", fleetId)
+	if err != nil {
+		return err
+	}
+	if fleet == nil {
+		return removeFromState(d, "fleet %d no longer exists", fleetId)
+	}
+
+	for resourceAttribute := range getFleetResourceSchema() {
+		switch resourceAttribute {
 		case "fleet_id":
 			_ = d.Set("fleet_id", fleet.FleetID)
 		case "slug":
@@ -174,10 +524,96 @@ func GetFleetDataSource(_ context.Context, d *schema.ResourceData, _ interface{}
 		case "uuid":
 			_ = d.Set("uuid", fleet.Uuid)
 		default:
-			return diag.Errorf("unhandled data source attribute: %s", dataSourceAttribute)
+			return diag.Errorf("unhandled resource attribute: %s", resourceAttribute)
 		}
 	}
 
-	d.SetId(GetFleetId(fleet.FleetID))
+	return nil
+}
+
+func ResourceFleetUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId, parseErr := ParseFleetId(d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	// Maps the mutable resource attributes to the fields of the application model
+	mutableFields := map[string]string{
+		"app_name":             "app_name",
+		"public":               "is_public",
+		"track_latest_release": "should_track_latest_release",
+		"archived":             "is_archived",
+	}
+
+	body := map[string]interface{}{}
+	for resourceAttribute, field := range mutableFields {
+		if d.HasChange(resourceAttribute) {
+			body[field] = d.Get(resourceAttribute)
+		}
+	}
+
+	if len(body) > 0 {
+		if err := UpdateFleet(client, fleetId, body); err != nil {
+			return err
+		}
+	}
+
+	return ResourceFleetRead(ctx, d, m)
+}
+
+func ResourceFleetDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId, parseErr := ParseFleetId(d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	if err := client.Delete(api.Application.ID(fleetId)); err != nil && !api.IsNotFound(err) {
+		return diag.Errorf("error deleting fleet: %s", err)
+	}
+
+	return nil
+}
+
+// ResourceFleetImport accepts the resource ID (`fleet:<fleet_id>`),
+// a bare fleet ID or a fleet slug
+func ResourceFleetImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	fleetId, err := resolveFleetImportId(client, d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(GetFleetId(fleetId))
+	return []*schema.ResourceData{d}, nil
+}
+
+// resolveFleetImportId turns `fleet:<fleet_id>`, `<fleet_id>` or `<slug>` into a fleet ID,
+// verifying that the fleet exists
+func resolveFleetImportId(client *api.Client, id string) (int, error) {
+	if fleetId, err := strconv.Atoi(strings.TrimPrefix(id, "fleet:")); err == nil {
+		fleet, diags := FetchFleet(client, "", fleetId)
+		if diags.HasError() {
+			return 0, diagsToError(diags)
+		}
+		return fleet.FleetID, nil
+	}
+
+	fleet, diags := FetchFleet(client, id, -1)
+	if diags.HasError() {
+		return 0, diagsToError(diags)
+	}
+	return fleet.FleetID, nil
+}
+
+func UpdateFleet(client *api.Client, fleetId int, body map[string]interface{}) diag.Diagnostics {
+	if err := client.Patch(api.Application.ID(fleetId), body); err != nil {
+		return diag.Errorf("error updating fleet: %s", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,191 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strconv"
+	"strings"
+)
+
+// OrganizationMembership is the format organization memberships are returned from the Balena API
+// when expanding their user and role
+type OrganizationMembership struct {
+	Id           int       `json:"id"`
+	Organization IDWrapper `json:"is_member_of__organization"`
+	User         []User    `json:"user"`
+	Role         []Role    `json:"organization_membership_role"`
+}
+
+func GetOrganizationMembershipId(membershipId int) string {
+	return fmt.Sprintf("organization-membership:%d", membershipId)
+}
+
+func resourceOrganizationMembership() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceOrganizationMembershipCreate,
+		ReadContext:   ResourceOrganizationMembershipRead,
+		UpdateContext: ResourceOrganizationMembershipUpdate,
+		DeleteContext: ResourceOrganizationMembershipDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceOrganizationMembershipImport,
+		},
+		Description: "Manage the membership of a user in an organization and their role. Destroying the resource removes the user from the organization.",
+		Schema: map[string]*schema.Schema{
+			"organization_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the organization.",
+			},
+			"username": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The username of the member.",
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "member",
+				ValidateFunc: validation.StringInSlice(organizationMembershipRoles, false),
+				Description:  "The role of the member, one of `" + strings.Join(organizationMembershipRoles, "`, `") + "`.",
+			},
+			"membership_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the membership.",
+			},
+			"user_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the member.",
+			},
+		},
+	}
+}
+
+// DescribeOrganizationMemberships lists the organization memberships matching an OData filter
+func DescribeOrganizationMemberships(client *api.Client, filter api.Filter) ([]OrganizationMembership, diag.Diagnostics) {
+	memberships, err := api.Get[OrganizationMembership](client, api.OrganizationMembership.All().
+		Select("id", "is_member_of__organization").
+		Expand("user", api.Options().Select("id", "username")).
+		Expand("organization_membership_role", api.Options().Select("id", "name")).
+		Filter(filter))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Organization Memberships: %s", err)
+	}
+	return memberships, nil
+}
+
+func ResourceOrganizationMembershipCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	roleId, err := FetchRoleId(client, api.OrganizationMembershipRole, d.Get("role").(string))
+	if err != nil {
+		return err
+	}
+
+	// The user is not visible to the provider before joining the organization,
+	// so Balena resolves the username rather than the provider looking up the user
+	membershipId, err := CreateObject(client, api.OrganizationMembership, map[string]interface{}{
+		"username":                     d.Get("username").(string),
+		"is_member_of__organization":   d.Get("organization_id").(int),
+		"organization_membership_role": roleId,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetOrganizationMembershipId(membershipId))
+	return ResourceOrganizationMembershipRead(ctx, d, m)
+}
+
+func ResourceOrganizationMembershipRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	membershipId, parseErr := ParseNumericId("organization-membership", d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	memberships, err := DescribeOrganizationMemberships(client, api.Eq("id", membershipId))
+	if err != nil {
+		return err
+	}
+	if len(memberships) == 0 {
+		return removeFromState(d, "organization membership %d no longer exists", membershipId)
+	}
+
+	membership := memberships[0]
+	_ = d.Set("membership_id", membership.Id)
+	_ = d.Set("organization_id", membership.Organization.ID)
+	if len(membership.User) > 0 {
+		_ = d.Set("user_id", membership.User[0].Id)
+		_ = d.Set("username", membership.User[0].Username)
+	}
+	if len(membership.Role) > 0 {
+		_ = d.Set("role", membership.Role[0].Name)
+	}
+	return nil
+}
+
+func ResourceOrganizationMembershipUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	if d.HasChange("role") {
+		roleId, err := FetchRoleId(client, api.OrganizationMembershipRole, d.Get("role").(string))
+		if err != nil {
+			return err
+		}
+
+		if err := UpdateObject(client, api.OrganizationMembership, d.Get("membership_id").(int), map[string]interface{}{
+			"organization_membership_role": roleId,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return ResourceOrganizationMembershipRead(ctx, d, m)
+}
+
+func ResourceOrganizationMembershipDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return DeleteObject(client, api.OrganizationMembership, d.Get("membership_id").(int))
+}
+
+// ResourceOrganizationMembershipImport accepts the resource ID (`organization-membership:<membership_id>`)
+// as well as `<organization_id>/<username>`
+func ResourceOrganizationMembershipImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	if strings.HasPrefix(d.Id(), "organization-membership:") {
+		return []*schema.ResourceData{d}, nil
+	}
+
+	parts := strings.SplitN(d.Id(), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid import ID %q, expected <organization_id>/<username>", d.Id())
+	}
+	organizationId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid organization ID %q in import ID %q", parts[0], d.Id())
+	}
+
+	memberships, diags := DescribeOrganizationMemberships(client,
+		api.And(api.Eq("is_member_of__organization", organizationId), api.Eq("user/username", parts[1])),
+	)
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+	if len(memberships) == 0 {
+		return nil, fmt.Errorf("%s is not a member of the organization %d", parts[1], organizationId)
+	}
+
+	d.SetId(GetOrganizationMembershipId(memberships[0].Id))
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,232 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"log"
+)
+
+type Organization struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Handle  string `json:"handle"`
+	Created string `json:"created_at"`
+}
+
+// Subscription is the format billing subscriptions are returned from the Balena API when expanding their plan
+type Subscription struct {
+	Id           int                `json:"id"`
+	Organization IDWrapper          `json:"is_for__organization"`
+	Plan         []SubscriptionPlan `json:"is_for__plan"`
+}
+
+type SubscriptionPlan struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	BillingCode string `json:"billing_code"`
+}
+
+func GetOrganizationId(organizationId int) string {
+	return fmt.Sprintf("organization:%d", organizationId)
+}
+
+// getOrganizationAttributesSchema the computed attributes of an organization, shared by
+// `balena_organization` and the elements of `balena_organizations`
+func getOrganizationAttributesSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"organization_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the organization.",
+		},
+		"handle": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The handle of the organization, used as the first part of the slugs of its fleets.",
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The display name of the organization.",
+		},
+		"billing_plan": {
+			Type:     schema.TypeString,
+			Computed: true,
+			Description: "The billing code of the current plan of the organization, e.g. `free` or `pilot`. " +
+				"Empty when the credentials of the provider cannot read the billing details of the organization.",
+		},
+		"created": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Timestamp of when the organization was created, represented as an ISO-Format string.",
+		},
+	}
+}
+
+func getOrganizationDataSourceSchema() map[string]*schema.Schema {
+	s := getOrganizationAttributesSchema()
+	s["organization_id"] = &schema.Schema{
+		Type:         schema.TypeInt,
+		Optional:     true,
+		Computed:     true,
+		ExactlyOneOf: []string{"organization_id", "handle"},
+		Description:  "The ID of the organization. Exactly one of `organization_id` and `handle` must be set.",
+	}
+	s["handle"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Computed:     true,
+		ExactlyOneOf: []string{"organization_id", "handle"},
+		Description:  "The handle of the organization, used as the first part of the slugs of its fleets.",
+	}
+	return s
+}
+
+func dataSourceOrganization() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetOrganizationDataSource,
+		Schema:      getOrganizationDataSourceSchema(),
+		Description: "Retrieve information about an organization given its `organization_id` or `handle`.",
+	}
+}
+
+func dataSourceOrganizations() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetOrganizationsDataSource,
+		Description: "List the organizations the credentials of the provider are a member of.",
+		Schema: map[string]*schema.Schema{
+			"organizations": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: getOrganizationAttributesSchema(),
+				},
+			},
+		},
+	}
+}
+
+// DescribeOrganizations lists the organizations matching an OData filter, which may be empty
+func DescribeOrganizations(client *api.Client, filter api.Filter) ([]Organization, diag.Diagnostics) {
+	organizations, err := api.Get[Organization](client, api.Organization.All().
+		Select("id", "name", "handle", "created_at").
+		Filter(filter).
+		OrderBy("handle asc"))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Organizations: %s", err)
+	}
+	return organizations, nil
+}
+
+func FetchOrganization(client *api.Client, handle string, organizationId int) (*Organization, diag.Diagnostics) {
+	var filter api.Filter
+	if handle != "" {
+		filter = api.Eq("handle", handle)
+	} else {
+		filter = api.Eq("id", organizationId)
+	}
+
+	organizations, err := DescribeOrganizations(client, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(organizations) == 0 {
+		return nil, diag.Errorf("no organization found")
+	} else if len(organizations) > 1 {
+		return nil, diag.Errorf("more than one organization found")
+	}
+
+	return &organizations[0], nil
+}
+
+// DescribeOrganizationPlans maps organization IDs to the billing code of their latest subscription.
+// Billing details are only visible to organization administrators, so a denied request yields an empty map
+func DescribeOrganizationPlans(client *api.Client, filter api.Filter) (map[int]string, diag.Diagnostics) {
+	plans := make(map[int]string)
+
+	subscriptions, err := api.Get[Subscription](client, api.Subscription.All().
+		Select("id", "is_for__organization").
+		Expand("is_for__plan", api.Options().Select("id", "title", "billing_code")).
+		Filter(filter).
+		OrderBy("starts_on__date desc"))
+	if api.IsUnauthorized(err) {
+		log.Printf("[WARN] the credentials of the provider cannot read billing subscriptions: %s", err)
+		return plans, nil
+	}
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Subscriptions: %s", err)
+	}
+
+	// Subscriptions are ordered newest first, so the first one of each organization is its current one
+	for _, subscription := range subscriptions {
+		if _, ok := plans[subscription.Organization.ID]; ok || len(subscription.Plan) == 0 {
+			continue
+		}
+		plans[subscription.Organization.ID] = subscription.Plan[0].BillingCode
+	}
+
+	return plans, nil
+}
+
+// flattenOrganization converts an organization to the attributes of `getOrganizationAttributesSchema`
+func flattenOrganization(organization Organization, plans map[int]string) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id": organization.Id,
+		"handle":          organization.Handle,
+		"name":            organization.Name,
+		"billing_plan":    plans[organization.Id],
+		"created":         organization.Created,
+	}
+}
+
+func GetOrganizationDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	organization, err := FetchOrganization(client, d.Get("handle").(string), d.Get("organization_id").(int))
+	if err != nil {
+		return err
+	}
+
+	plans, err := DescribeOrganizationPlans(client, api.Eq("is_for__organization", organization.Id))
+	if err != nil {
+		return err
+	}
+
+	for dataSourceAttribute, value := range flattenOrganization(*organization, plans) {
+		if err := d.Set(dataSourceAttribute, value); err != nil {
+			return diag.Errorf("failed to set %s: %v", dataSourceAttribute, err)
+		}
+	}
+
+	d.SetId(GetOrganizationId(organization.Id))
+	return nil
+}
+
+func GetOrganizationsDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	organizations, err := DescribeOrganizations(client, "")
+	if err != nil {
+		return err
+	}
+
+	plans, err := DescribeOrganizationPlans(client, "")
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, organization := range organizations {
+		response = append(response, flattenOrganization(organization, plans))
+	}
+	if err := d.Set("organizations", response); err != nil {
+		return diag.Errorf("failed to set organizations: %v", err)
+	}
+
+	d.SetId("organizations")
+	return nil
+}
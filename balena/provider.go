@@ -3,16 +3,14 @@ package balena
 import (
 	"context"
 	"fmt"
-	"github.com/go-resty/resty/v2"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/kassett/terraform-provider-balena/balena/api"
 	"os"
 	"path/filepath"
 	"strings"
-)
-
-var (
-	client *APIClient
+	"time"
 )
 
 func getBalenaTokenDir() string {
@@ -20,19 +18,6 @@ func getBalenaTokenDir() string {
 	return filepath.Join(home, ".balena", "token")
 }
 
-type APIClient struct {
-	client *resty.Client
-}
-
-// NewAPIClient initializes a new API client with a global header
-func NewAPIClient(baseURL, headerKey, headerValue string) {
-	c := resty.New().
-		SetBaseURL(baseURL).
-		SetHeader(headerKey, headerValue) // Set global header
-
-	client = &APIClient{client: c}
-}
-
 func Provider() *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
@@ -73,32 +58,116 @@ func Provider() *schema.Provider {
 				Default:     false,
 				DefaultFunc: schema.EnvDefaultFunc("BALENA_API_KEY", nil),
 			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      5,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description: "How often a request failing with 429 Too Many Requests, a 5xx or a network error is retried. " +
+					"Creating an object is only retried after checking that the failed attempt did not create it.",
+			},
+			"max_retry_wait_seconds": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      30,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description: "The longest wait before a retry, in seconds. Waits grow exponentially with jitter, " +
+					"or follow the `Retry-After` header when Balena sends one, up to this limit.",
+			},
+			"requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validation.FloatAtLeast(0),
+				Description: "The average rate at which the provider sends requests to Balena, including retries, " +
+					"so that large plans and applies stay under its rate limits. `0` disables the limit.",
+			},
+			"request_burst": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      20,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "How many requests may be sent at once after a quiet period, above `requests_per_second`.",
+			},
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      5,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description: "The most requests the provider waits on at the same time, regardless of Terraform's `-parallelism`. " +
+					"`0` removes the cap.",
+			},
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
-			"balena_fleet":                      dataSourceFleet(),
-			"balena_device":                     dataSourceDevice(),
-			"balena_device_tags":                dataSourceDeviceTags(),
-			"balena_services":                   dataSourceServices(),
-			"balena_fleet_variables":            dataSourceFleetVariables(),
-			"balena_fleet_variable":             dataSourceFleetVariable(),
-			"balena_sensitive_fleet_variable":   dataSourceFleetVariableSensitive(),
-			"balena_service_variables":          dataSourceServiceVariables(),
-			"balena_service_variable":           dataSourceServiceVariable(),
-			"balena_sensitive_service_variable": dataSourceServiceVariableSensitive(),
-			//"balena_device_variables":  dataSourceDeviceVariables(), // TODO Figure out the correct hierarchy of variables
+			"balena_fleet":                             dataSourceFleet(),
+			"balena_fleets":                            dataSourceFleets(),
+			"balena_device":                            dataSourceDevice(),
+			"balena_devices":                           dataSourceDevices(),
+			"balena_device_tags":                       dataSourceDeviceTags(),
+			"balena_services":                          dataSourceServices(),
+			"balena_fleet_variables":                   dataSourceFleetVariables(),
+			"balena_fleet_variable":                    dataSourceFleetVariable(),
+			"balena_sensitive_fleet_variable":          dataSourceFleetVariableSensitive(),
+			"balena_service_variables":                 dataSourceServiceVariables(),
+			"balena_service_variable":                  dataSourceServiceVariable(),
+			"balena_sensitive_service_variable":        dataSourceServiceVariableSensitive(),
+			"balena_device_variables":                  dataSourceDeviceVariables(),
+			"balena_device_variable":                   dataSourceDeviceVariable(),
+			"balena_sensitive_device_variable":         dataSourceDeviceVariableSensitive(),
+			"balena_effective_variables":               dataSourceEffectiveVariables(),
+			"balena_device_service_variables":          dataSourceDeviceServiceVariables(),
+			"balena_device_service_variable":           dataSourceDeviceServiceVariable(),
+			"balena_sensitive_device_service_variable": dataSourceDeviceServiceVariableSensitive(),
+			"balena_fleet_config_variables":            dataSourceFleetConfigVariables(),
+			"balena_device_config_variables":           dataSourceDeviceConfigVariables(),
+			"balena_fleet_tags":                        dataSourceFleetTags(),
+			"balena_release":                           dataSourceRelease(),
+			"balena_releases":                          dataSourceReleases(),
+			"balena_release_tags":                      dataSourceReleaseTags(),
+			"balena_device_type":                       dataSourceDeviceType(),
+			"balena_device_types":                      dataSourceDeviceTypes(),
+			"balena_cpu_architecture":                  dataSourceCpuArchitecture(),
+			"balena_cpu_architectures":                 dataSourceCpuArchitectures(),
+			"balena_organization":                      dataSourceOrganization(),
+			"balena_organizations":                     dataSourceOrganizations(),
+			"balena_organization_membership_roles":     dataSourceOrganizationMembershipRoles(),
+			"balena_application_membership_roles":      dataSourceApplicationMembershipRoles(),
+			"balena_provisioning_keys":                 dataSourceProvisioningKeys(),
+			"balena_api_keys":                          dataSourceApiKeys(),
+			"balena_ssh_keys":                          dataSourceSshKeys(),
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"balena_service_variable":           resourceServiceVariable(),
-			"balena_sensitive_service_variable": resourceServiceVariableSensitive(),
-			"balena_fleet_variable":             resourceFleetVariable(),
-			"balena_sensitive_fleet_variable":   resourceFleetVariableSensitive(),
+			"balena_fleet":                             resourceFleet(),
+			"balena_device":                            resourceDevice(),
+			"balena_service_variable":                  resourceServiceVariable(),
+			"balena_sensitive_service_variable":        resourceServiceVariableSensitive(),
+			"balena_fleet_variable":                    resourceFleetVariable(),
+			"balena_sensitive_fleet_variable":          resourceFleetVariableSensitive(),
+			"balena_device_variable":                   resourceDeviceVariable(),
+			"balena_sensitive_device_variable":         resourceDeviceVariableSensitive(),
+			"balena_device_service_variable":           resourceDeviceServiceVariable(),
+			"balena_sensitive_device_service_variable": resourceDeviceServiceVariableSensitive(),
+			"balena_fleet_config_variable":             resourceFleetConfigVariable(),
+			"balena_device_config_variable":            resourceDeviceConfigVariable(),
+			"balena_device_tag":                        resourceDeviceTag(),
+			"balena_device_tags":                       resourceDeviceTags(),
+			"balena_fleet_tag":                         resourceFleetTag(),
+			"balena_fleet_release_pin":                 resourceFleetReleasePin(),
+			"balena_release_tag":                       resourceReleaseTag(),
+			"balena_organization_membership":           resourceOrganizationMembership(),
+			"balena_team":                              resourceTeam(),
+			"balena_team_membership":                   resourceTeamMembership(),
+			"balena_team_application_access":           resourceTeamApplicationAccess(),
+			"balena_provisioning_key":                  resourceProvisioningKey(),
+			"balena_api_key":                           resourceApiKey(),
+			"balena_ssh_key":                           resourceSshKey(),
 		},
 		ConfigureContextFunc: providerConfigure,
 	}
 }
 
-func providerConfigure(_ context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	var balenaUrl = d.Get("balena_url").(string)
 	var balenaUseEnvVar = d.Get("use_env_var").(bool)
 	var balenaTokenPath = d.Get("balena_token_path").(string)
@@ -121,14 +190,27 @@ func providerConfigure(_ context.Context, d *schema.ResourceData) (interface{},
 		token = string(contents)
 	}
 
-	NewAPIClient(balenaUrl, "Authorization", fmt.Sprintf("Bearer %s", token))
+	// The CRUD functions do not pass their context on to the client, so requests are cancelled
+	// through the context the SDK cancels when Terraform is interrupted
+	stopCtx, _ := schema.StopContext(ctx)
 
-	res, err := client.client.R().Get("/v7/organization")
-	if err != nil {
-		return nil, diag.Errorf("there was an error connecting to balena: %s", err)
-	} else if res.StatusCode() == 401 || res.StatusCode() == 403 {
+	// The client is returned as the meta of the provider rather than stored globally,
+	// so that aliased providers, e.g. for staging and production, stay isolated
+	client := api.NewClient(balenaUrl, token, api.Settings{
+		MaxRetries:            d.Get("max_retries").(int),
+		MaxRetryWait:          time.Duration(d.Get("max_retry_wait_seconds").(int)) * time.Second,
+		RequestsPerSecond:     d.Get("requests_per_second").(float64),
+		RequestBurst:          d.Get("request_burst").(int),
+		MaxConcurrentRequests: d.Get("max_concurrent_requests").(int),
+		Context:               stopCtx,
+	})
+
+	_, err := api.Get[Organization](client, api.Organization.All().Select("id").Top(1))
+	if api.IsUnauthorized(err) {
 		return nil, diag.Errorf("the credentials for the Balena provider may need to be refreshed")
+	} else if err != nil {
+		return nil, diag.Errorf("there was an error connecting to balena: %s", err)
 	}
 
-	return nil, nil
+	return client, nil
 }
@@ -0,0 +1,215 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+)
+
+func GetProvisioningKeyId(apiKeyId int) string {
+	return fmt.Sprintf("provisioning-key:%d", apiKeyId)
+}
+
+func GetProvisioningKeysId(fleetId int) string {
+	return fmt.Sprintf("provisioning-keys:%d", fleetId)
+}
+
+func dataSourceProvisioningKeys() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetProvisioningKeysDataSource,
+		Description: "List the provisioning keys of a fleet, without the keys themselves.",
+		Schema: map[string]*schema.Schema{
+			"fleet_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				Description: "The ID of the fleet to list the provisioning keys of.",
+			},
+			"provisioning_keys": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The provisioning keys of the fleet, newest first.",
+				Elem: &schema.Resource{
+					Schema: getApiKeyAttributesSchema(),
+				},
+			},
+		},
+	}
+}
+
+func resourceProvisioningKey() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceProvisioningKeyCreate,
+		ReadContext:   ResourceProvisioningKeyRead,
+		UpdateContext: ResourceProvisioningKeyUpdate,
+		DeleteContext: ResourceProvisioningKeyDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceProvisioningKeyImport,
+		},
+		Description: "Generate a provisioning key with which devices register themselves in a fleet. " +
+			"The key is only available when it is generated, so it is empty after an import. Destroying the resource revokes the key.",
+		Schema: map[string]*schema.Schema{
+			"fleet_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the fleet devices provisioned with the key join.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the key, which has to be unique among the provisioning keys of the fleet.",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The description of the key.",
+			},
+			"expiry_date": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateFunc:     validation.IsRFC3339Time,
+				DiffSuppressFunc: suppressEquivalentExpiryDate,
+				Description:      "The time the key expires, as an RFC 3339 timestamp. Changing this generates a new key.",
+			},
+			"key": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The provisioning key.",
+			},
+			"key_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the key.",
+			},
+			"created": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the key was created, represented as a string in ISO-Format.",
+			},
+		},
+	}
+}
+
+func GetProvisioningKeysDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+
+	fleet, err := FetchFleet(client, "", fleetId)
+	if err != nil {
+		return err
+	}
+
+	apiKeys, err := DescribeApiKeys(client, fleet.Actor.ID, "")
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, apiKey := range apiKeys {
+		response = append(response, flattenApiKey(apiKey))
+	}
+	if err := d.Set("provisioning_keys", response); err != nil {
+		return diag.Errorf("failed to set provisioning_keys: %v", err)
+	}
+
+	d.SetId(GetProvisioningKeysId(fleetId))
+	return nil
+}
+
+func ResourceProvisioningKeyCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleet, err := FetchFleet(client, "", d.Get("fleet_id").(int))
+	if err != nil {
+		return err
+	}
+
+	key, apiKeyId, err := GenerateApiKey(client,
+		fmt.Sprintf("/api-key/application/%d/provisioning", fleet.FleetID),
+		fleet.Actor.ID,
+		d.Get("name").(string),
+		d.Get("description").(string),
+		d.Get("expiry_date").(string),
+	)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetProvisioningKeyId(apiKeyId))
+	_ = d.Set("key", key)
+	return ResourceProvisioningKeyRead(ctx, d, m)
+}
+
+func ResourceProvisioningKeyRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	apiKeyId, parseErr := ParseNumericId("provisioning-key", d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	apiKey, err := LookupApiKey(client, apiKeyId)
+	if err != nil {
+		return err
+	}
+	if apiKey == nil {
+		return removeFromState(d, "provisioning key %d no longer exists", apiKeyId)
+	}
+
+	// Imported keys only know their actor, resolve it to the fleet
+	if d.Get("fleet_id").(int) == 0 {
+		fleets, err := DescribeFleets(client, api.Eq("actor", apiKey.Actor.ID))
+		if err != nil {
+			return err
+		}
+		if len(fleets) == 0 {
+			return diag.Errorf("the api key %d is not a provisioning key of a fleet", apiKeyId)
+		}
+		_ = d.Set("fleet_id", fleets[0].FleetID)
+	}
+
+	_ = d.Set("key_id", apiKey.Id)
+	_ = d.Set("name", apiKey.Name)
+	_ = d.Set("description", apiKey.Description)
+	_ = d.Set("expiry_date", apiKey.ExpiryDate)
+	_ = d.Set("created", apiKey.Created)
+	return nil
+}
+
+func ResourceProvisioningKeyUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	if d.HasChanges("name", "description") {
+		if err := UpdateObject(client, api.ApiKey, d.Get("key_id").(int), map[string]interface{}{
+			"name":        d.Get("name").(string),
+			"description": d.Get("description").(string),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return ResourceProvisioningKeyRead(ctx, d, m)
+}
+
+func ResourceProvisioningKeyDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return DeleteObject(client, api.ApiKey, d.Get("key_id").(int))
+}
+
+// ResourceProvisioningKeyImport accepts the resource ID (`provisioning-key:<key_id>`) as well as a bare key ID
+func ResourceProvisioningKeyImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	apiKeyId, err := ParseNumericId("provisioning-key", d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(GetProvisioningKeyId(apiKeyId))
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,213 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strconv"
+	"strings"
+)
+
+func GetReleaseTagsId(releaseId int) string {
+	return fmt.Sprintf("release-tags:%d", releaseId)
+}
+
+func GetReleaseTagId(releaseId int, tagKey string) string {
+	return fmt.Sprintf("release-tag:%d:%s", releaseId, tagKey)
+}
+
+func dataSourceReleaseTags() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetReleaseTagsDataSource,
+		Description: "Get all tags of a release given its `release_id`.",
+		Schema: map[string]*schema.Schema{
+			"release_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				Description: "The ID of the release for which the tags are being requested.",
+			},
+			"tags": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "A key-value pair of release tags.",
+			},
+		},
+	}
+}
+
+func resourceReleaseTag() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceReleaseTagCreate,
+		ReadContext:   ResourceReleaseTagRead,
+		UpdateContext: ResourceReleaseTagUpdate,
+		DeleteContext: ResourceReleaseTagDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceReleaseTagImport,
+		},
+		Description: "Manage a single tag of a release, e.g. to mark it as approved before pinning a fleet to it.",
+		Schema: map[string]*schema.Schema{
+			"release_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the release to tag.",
+			},
+			"tag_key": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The key of the tag.",
+			},
+			"value": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The value of the tag.",
+			},
+		},
+	}
+}
+
+func FetchReleaseTags(client *api.Client, releaseId int) ([]ReleaseTag, diag.Diagnostics) {
+	tags, err := api.Get[ReleaseTag](client, api.ReleaseTag.All().Filter(api.Eq("release", releaseId)))
+	if err != nil {
+		return nil, diag.Errorf("retrieving release tags for %d failed with the error %s", releaseId, err)
+	}
+	return tags, nil
+}
+
+func existingReleaseTags(tags []ReleaseTag) map[string]existingTag {
+	existing := make(map[string]existingTag)
+	for _, tag := range tags {
+		existing[tag.Key] = existingTag{id: tag.Id, value: tag.Value}
+	}
+	return existing
+}
+
+func GetReleaseTagsDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	releaseId := d.Get("release_id").(int)
+
+	tags, err := FetchReleaseTags(client, releaseId)
+	if err != nil {
+		return err
+	}
+
+	newTags := map[string]string{}
+	for _, releaseTag := range tags {
+		newTags[releaseTag.Key] = releaseTag.Value
+	}
+	_ = d.Set("release_id", releaseId)
+	_ = d.Set("tags", newTags)
+
+	d.SetId(GetReleaseTagsId(releaseId))
+	return nil
+}
+
+func ResourceReleaseTagCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	releaseId := d.Get("release_id").(int)
+	tagKey := d.Get("tag_key").(string)
+
+	tags, err := FetchReleaseTags(client, releaseId)
+	if err != nil {
+		return err
+	}
+	if _, ok := existingReleaseTags(tags)[tagKey]; ok {
+		return diag.Errorf("tag %s already exists on the release %d", tagKey, releaseId)
+	}
+
+	if err := CreateTag(client, api.ReleaseTag, "release", releaseId, tagKey, d.Get("value").(string)); err != nil {
+		return err
+	}
+
+	d.SetId(GetReleaseTagId(releaseId, tagKey))
+	return ResourceReleaseTagRead(ctx, d, m)
+}
+
+// ResourceReleaseTagRead removes a tag deleted outside of Terraform from state
+func ResourceReleaseTagRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	releaseId := d.Get("release_id").(int)
+	tagKey := d.Get("tag_key").(string)
+
+	tags, err := FetchReleaseTags(client, releaseId)
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingReleaseTags(tags)[tagKey]
+	if !ok {
+		return removeFromState(d, "tag %s no longer exists on the release %d", tagKey, releaseId)
+	}
+
+	_ = d.Set("value", tag.value)
+	return nil
+}
+
+func ResourceReleaseTagUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	releaseId := d.Get("release_id").(int)
+	tagKey := d.Get("tag_key").(string)
+
+	tags, err := FetchReleaseTags(client, releaseId)
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingReleaseTags(tags)[tagKey]
+	if !ok {
+		return diag.Errorf("no tag %s configured for the release %d", tagKey, releaseId)
+	}
+
+	if err := UpdateTag(client, api.ReleaseTag, tag.id, d.Get("value").(string)); err != nil {
+		return err
+	}
+	return ResourceReleaseTagRead(ctx, d, m)
+}
+
+func ResourceReleaseTagDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	tags, err := FetchReleaseTags(client, d.Get("release_id").(int))
+	if err != nil {
+		return err
+	}
+
+	tag, ok := existingReleaseTags(tags)[d.Get("tag_key").(string)]
+	if !ok {
+		// Already deleted outside of Terraform
+		return nil
+	}
+
+	return DeleteTag(client, api.ReleaseTag, tag.id)
+}
+
+// ResourceReleaseTagImport accepts the resource ID (`release-tag:<release_id>:<tag_key>`)
+// as well as `<release_id>/<tag_key>`
+func ResourceReleaseTagImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	var parts []string
+	if strings.HasPrefix(d.Id(), "release-tag:") {
+		parts = strings.SplitN(strings.TrimPrefix(d.Id(), "release-tag:"), ":", 2)
+	} else {
+		parts = strings.SplitN(d.Id(), "/", 2)
+	}
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid import ID %q, expected <release_id>/<tag_key>", d.Id())
+	}
+
+	releaseId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid release ID %q in import ID %q", parts[0], d.Id())
+	}
+
+	_ = d.Set("release_id", releaseId)
+	_ = d.Set("tag_key", parts[1])
+	d.SetId(GetReleaseTagId(releaseId, parts[1]))
+	return []*schema.ResourceData{d}, nil
+}
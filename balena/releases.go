@@ -0,0 +1,299 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strings"
+)
+
+// releaseStatuses are the statuses a release goes through while it is built
+var releaseStatuses = []string{"running", "success", "failed", "error", "cancelled", "interrupted"}
+
+// Release is the format releases are returned from the Balena API when expanding their tags
+type Release struct {
+	Id            int          `json:"id"`
+	Commit        string       `json:"commit"`
+	Status        string       `json:"status"`
+	Semver        string       `json:"semver"`
+	RawVersion    string       `json:"raw_version"`
+	Created       string       `json:"created_at"`
+	IsFinal       bool         `json:"is_final"`
+	IsInvalidated bool         `json:"is_invalidated"`
+	FleetId       IDWrapper    `json:"belongs_to__application"`
+	Tags          []ReleaseTag `json:"release_tag"`
+}
+
+type ReleaseTag struct {
+	Id    int    `json:"id"`
+	Key   string `json:"tag_key"`
+	Value string `json:"value"`
+}
+
+func GetReleaseId(releaseId int) string {
+	return fmt.Sprintf("release:%d", releaseId)
+}
+
+func GetReleasesId(fleetId int) string {
+	return fmt.Sprintf("releases:%d", fleetId)
+}
+
+// getReleaseAttributesSchema the computed attributes of a release, shared by
+// `balena_release` and the elements of `balena_releases`
+func getReleaseAttributesSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"release_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the release.",
+		},
+		"commit": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The commit hash of the release.",
+		},
+		"status": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The build status of the release, one of `" + strings.Join(releaseStatuses, "`, `") + "`.",
+		},
+		"semver": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The semantic version of the release.",
+		},
+		"raw_version": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The full version of the release, including the revision and the pre-release identifiers of draft releases.",
+		},
+		"created": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The time the release was created, represented as a string in ISO-Format.",
+		},
+		"is_final": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the release is final rather than a draft.",
+		},
+		"is_invalidated": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the release has been invalidated, preventing devices from downloading it.",
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "A key-value pair of release tags.",
+		},
+	}
+}
+
+func getReleaseDataSourceSchema() map[string]*schema.Schema {
+	s := getReleaseAttributesSchema()
+	s["fleet_id"] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Required:    true,
+		Description: "The ID of the fleet the release belongs to.",
+	}
+	s["release_id"] = &schema.Schema{
+		Type:          schema.TypeInt,
+		Optional:      true,
+		Computed:      true,
+		ConflictsWith: []string{"commit", "semver", "raw_version"},
+		Description:   "The ID of the release to look up.",
+	}
+	s["commit"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		Computed:      true,
+		ConflictsWith: []string{"release_id", "semver", "raw_version"},
+		Description:   "The commit hash, or a prefix of it, of the release to look up.",
+	}
+	s["semver"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		Computed:      true,
+		ConflictsWith: []string{"release_id", "commit", "raw_version"},
+		Description:   "The semantic version of the release to look up. The latest final release with this version is returned.",
+	}
+	s["raw_version"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		Computed:      true,
+		ConflictsWith: []string{"release_id", "commit", "semver"},
+		Description:   "The full version of the release to look up.",
+	}
+	return s
+}
+
+func getReleasesDataSourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"fleet_id": {
+			Type:        schema.TypeInt,
+			Required:    true,
+			Description: "The ID of the fleet to list the releases of.",
+		},
+		"status": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice(releaseStatuses, false),
+			Description:  "Only list releases with this build status.",
+		},
+		"is_final": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "When set, only list final (`true`) or draft (`false`) releases.",
+		},
+		"releases": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The releases of the fleet, newest first.",
+			Elem: &schema.Resource{
+				Schema: getReleaseAttributesSchema(),
+			},
+		},
+	}
+}
+
+func dataSourceRelease() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetReleaseDataSource,
+		Schema:      getReleaseDataSourceSchema(),
+		Description: "Find a release of a fleet by its `release_id`, `commit`, `semver` or `raw_version`. " +
+			"When none of them is set, the latest successful, final and valid release is returned.",
+	}
+}
+
+func dataSourceReleases() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetReleasesDataSource,
+		Schema:      getReleasesDataSourceSchema(),
+		Description: "List the releases of a fleet, optionally filtered by status and finality.",
+	}
+}
+
+// DescribeReleases lists the releases of a fleet matching an additional OData filter, newest first
+func DescribeReleases(client *api.Client, fleetId int, filter api.Filter, top int) ([]Release, diag.Diagnostics) {
+	releases, err := api.Get[Release](client, api.Release.All().
+		Filter(api.And(api.Eq("belongs_to__application", fleetId), filter)).
+		Expand("release_tag", api.Options().Select("id", "tag_key", "value")).
+		OrderBy("created_at desc").
+		Top(top))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Releases: %s", err)
+	}
+	return releases, nil
+}
+
+// FindRelease finds a single release of a fleet by ID, commit (prefix), semver of a final release or raw version,
+// falling back to the latest successful final release when none is given
+func FindRelease(client *api.Client, fleetId int, releaseId int, commit string, semver string, rawVersion string) (*Release, diag.Diagnostics) {
+	var filter api.Filter
+	switch {
+	case releaseId != 0:
+		filter = api.Eq("id", releaseId)
+	case commit != "":
+		filter = api.StartsWith("commit", commit)
+	case semver != "":
+		// Drafts share the semver of the final release they precede, so only final releases are considered
+		filter = api.And(api.Eq("semver", semver), api.Eq("is_final", true))
+	case rawVersion != "":
+		filter = api.Eq("raw_version", rawVersion)
+	default:
+		filter = api.And(api.Eq("status", "success"), api.Eq("is_final", true), api.Eq("is_invalidated", false))
+	}
+
+	releases, err := DescribeReleases(client, fleetId, filter, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(releases) == 0 {
+		return nil, diag.Errorf("no release found for the fleet %d", fleetId)
+	} else if len(releases) > 1 && commit != "" {
+		return nil, diag.Errorf("more than one release found for the commit %s, use a longer commit hash", commit)
+	}
+
+	return &releases[0], nil
+}
+
+// flattenRelease converts a release to the attributes of `getReleaseAttributesSchema`
+func flattenRelease(release Release) map[string]interface{} {
+	tags := map[string]string{}
+	for _, tag := range release.Tags {
+		tags[tag.Key] = tag.Value
+	}
+
+	return map[string]interface{}{
+		"release_id":     release.Id,
+		"commit":         release.Commit,
+		"status":         release.Status,
+		"semver":         release.Semver,
+		"raw_version":    release.RawVersion,
+		"created":        release.Created,
+		"is_final":       release.IsFinal,
+		"is_invalidated": release.IsInvalidated,
+		"tags":           tags,
+	}
+}
+
+func GetReleaseDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+	release, err := FindRelease(client,
+		fleetId,
+		d.Get("release_id").(int),
+		d.Get("commit").(string),
+		d.Get("semver").(string),
+		d.Get("raw_version").(string),
+	)
+	if err != nil {
+		return err
+	}
+
+	for dataSourceAttribute, value := range flattenRelease(*release) {
+		if err := d.Set(dataSourceAttribute, value); err != nil {
+			return diag.Errorf("failed to set %s: %v", dataSourceAttribute, err)
+		}
+	}
+	_ = d.Set("fleet_id", fleetId)
+
+	d.SetId(GetReleaseId(release.Id))
+	return nil
+}
+
+func GetReleasesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	fleetId := d.Get("fleet_id").(int)
+
+	var filters []api.Filter
+	if status := d.Get("status").(string); status != "" {
+		filters = append(filters, api.Eq("status", status))
+	}
+	if isFinal := d.GetRawConfig().GetAttr("is_final"); !isFinal.IsNull() {
+		filters = append(filters, api.Eq("is_final", isFinal.True()))
+	}
+
+	releases, err := DescribeReleases(client, fleetId, api.And(filters...), 0)
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, release := range releases {
+		response = append(response, flattenRelease(release))
+	}
+	if err := d.Set("releases", response); err != nil {
+		return diag.Errorf("failed to set releases: %v", err)
+	}
+
+	d.SetId(GetReleasesId(fleetId))
+	return nil
+}
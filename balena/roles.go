@@ -0,0 +1,117 @@
+package balena
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strings"
+)
+
+// organizationMembershipRoles are the roles of the members of an organization
+var organizationMembershipRoles = []string{"administrator", "member"}
+
+// applicationMembershipRoles are the roles with which users and teams access a fleet
+var applicationMembershipRoles = []string{"developer", "operator", "observer"}
+
+// Role is the format of both organization and application membership roles
+type Role struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func getRolesDataSourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"roles": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"role_id": {
+						Type:        schema.TypeInt,
+						Computed:    true,
+						Description: "The ID of the role.",
+					},
+					"name": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The name of the role.",
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceOrganizationMembershipRoles() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetOrganizationMembershipRolesDataSource,
+		Schema:      getRolesDataSourceSchema(),
+		Description: "List the roles members can have in an organization, i.e. `" + strings.Join(organizationMembershipRoles, "`, `") + "`.",
+	}
+}
+
+func dataSourceApplicationMembershipRoles() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetApplicationMembershipRolesDataSource,
+		Schema:      getRolesDataSourceSchema(),
+		Description: "List the roles with which users and teams can access a fleet, i.e. `" + strings.Join(applicationMembershipRoles, "`, `") + "`.",
+	}
+}
+
+// DescribeRoles lists the roles of a role resource, e.g. `organization_membership_role`
+func DescribeRoles(client *api.Client, resource api.Resource) ([]Role, diag.Diagnostics) {
+	roles, err := api.Get[Role](client, resource.All().Select("id", "name").OrderBy("name asc"))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving %s: %s", resource, err)
+	}
+	return roles, nil
+}
+
+// FetchRoleId resolves the name of a role of a role resource to its ID
+func FetchRoleId(client *api.Client, resource api.Resource, name string) (int, diag.Diagnostics) {
+	roles, err := DescribeRoles(client, resource)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, role := range roles {
+		if role.Name == name {
+			return role.Id, nil
+		}
+	}
+	return 0, diag.Errorf("no %s %s found", resource, name)
+}
+
+func setRolesDataSource(client *api.Client, d *schema.ResourceData, resource api.Resource) diag.Diagnostics {
+	roles, err := DescribeRoles(client, resource)
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, role := range roles {
+		response = append(response, map[string]interface{}{
+			"role_id": role.Id,
+			"name":    role.Name,
+		})
+	}
+	if err := d.Set("roles", response); err != nil {
+		return diag.Errorf("failed to set roles: %v", err)
+	}
+
+	d.SetId(strings.ReplaceAll(string(resource), "_", "-") + "s")
+	return nil
+}
+
+func GetOrganizationMembershipRolesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return setRolesDataSource(client, d, api.OrganizationMembershipRole)
+}
+
+func GetApplicationMembershipRolesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return setRolesDataSource(client, d, api.ApplicationMembershipRole)
+}
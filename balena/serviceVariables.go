@@ -2,10 +2,12 @@ package balena
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strconv"
+	"strings"
 )
 
 // ServiceVariable is the format that service variables are returned
@@ -17,11 +19,6 @@ type ServiceVariable struct {
 	Created string `json:"created_at"`
 }
 
-// ServiceVariableResponse -- Balena wraps all responses in the key `d`
-type ServiceVariableResponse struct {
-	ServiceVariables []ServiceVariable `json:"d"`
-}
-
 func GetSingularServiceVariableId(serviceId int, variableName string) string {
 	return fmt.Sprintf("service-variable:%d:%s", serviceId, variableName)
 }
@@ -90,28 +87,21 @@ func getServiceVariableDataSourceSchema(sensitive bool) map[string]*schema.Schem
 }
 
 // ServiceVariablesApiCall actually makes the call to the Balena API to get all variables for a service
-func ServiceVariablesApiCall(serviceId int) ([]ServiceVariable, diag.Diagnostics) {
-	endpoint := fmt.Sprintf("/v7/service_environment_variable?$filter=%s", fmt.Sprintf("service eq %d", serviceId))
-	res, err := client.client.R().Get(endpoint)
+func ServiceVariablesApiCall(client *api.Client, serviceId int) ([]ServiceVariable, diag.Diagnostics) {
+	serviceVariables, err := api.Get[ServiceVariable](client, api.ServiceEnvironmentVariable.All().
+		Filter(api.Eq("service", serviceId)))
 	if err != nil {
-		return nil, diag.FromErr(err)
-	}
-	if !is200Level(res.StatusCode()) {
-		return nil, diag.FromErr(fmt.Errorf("error retrieving Fleet Variables: %s", res.Status()))
-	}
-
-	var serviceVariables ServiceVariableResponse
-	if err := json.Unmarshal(res.Body(), &serviceVariables); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("failed to unmarshal response from Balena service variables API: %w", err))
+		return nil, diag.Errorf("error retrieving Service Variables: %s", err)
 	}
-
-	return serviceVariables.ServiceVariables, nil
+	return serviceVariables, nil
 }
 
 // GetServiceVariablesDataSource is used for the data source and the ReadContext function
-func GetServiceVariablesDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func GetServiceVariablesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	serviceId := d.Get("service_id").(int)
-	variables, err := ServiceVariablesApiCall(serviceId)
+	variables, err := ServiceVariablesApiCall(client, serviceId)
 	if err != nil {
 		return err
 	}
@@ -138,13 +128,15 @@ func GetServiceVariablesDataSource(_ context.Context, d *schema.ResourceData, _
 
 // GetServiceVariableDataSource to get a single service variable
 // In practice, we still fetch all the service variables
-func GetServiceVariableDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func GetServiceVariableDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	serviceId := d.Get("service_id").(int)
 	variableName := d.Get("variable_name").(string)
 
 	var variableValue string
 	found := false
-	variables, err := ServiceVariablesApiCall(serviceId)
+	variables, err := ServiceVariablesApiCall(client, serviceId)
 	if err != nil {
 		return err
 	}
@@ -190,8 +182,11 @@ func privateServiceVariableResource(sensitive bool) *schema.Resource {
 	return &schema.Resource{
 		CreateContext: ResourceServiceVariableCreate,
 		UpdateContext: ResourceServiceVariableUpdate,
-		ReadContext:   GetServiceVariableDataSource,
+		ReadContext:   ResourceServiceVariableRead,
 		DeleteContext: ResourceServiceVariableDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceServiceVariableImport,
+		},
 		Schema: map[string]*schema.Schema{
 			"service_id": {
 				Type:     schema.TypeInt,
@@ -199,9 +194,10 @@ func privateServiceVariableResource(sensitive bool) *schema.Resource {
 				ForceNew: true,
 			},
 			"variable_name": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateEnvironmentVariableName,
 			},
 			"value": {
 				Type:      schema.TypeString,
@@ -212,12 +208,14 @@ func privateServiceVariableResource(sensitive bool) *schema.Resource {
 	}
 }
 
-func ResourceServiceVariableCreate(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func ResourceServiceVariableCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	serviceId := d.Get("service_id").(int)
 	variableName := d.Get("variable_name").(string)
 	variableValue := d.Get("value").(string)
 
-	serviceVariables, err := ServiceVariablesApiCall(serviceId)
+	serviceVariables, err := ServiceVariablesApiCall(client, serviceId)
 	if err != nil {
 		return err
 	}
@@ -228,7 +226,7 @@ func ResourceServiceVariableCreate(_ context.Context, d *schema.ResourceData, _
 		}
 	}
 
-	err = CreateServiceVariable(serviceId, variableName, variableValue)
+	err = CreateServiceVariable(client, serviceId, variableName, variableValue)
 	if err != nil {
 		return err
 	}
@@ -237,108 +235,159 @@ func ResourceServiceVariableCreate(_ context.Context, d *schema.ResourceData, _
 	return nil
 }
 
-func ResourceServiceVariableUpdate(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+// LookupServiceVariable finds a single variable of a service, returning nil without an error
+// when the variable does not exist
+func LookupServiceVariable(client *api.Client, serviceId int, variableName string) (*ServiceVariable, diag.Diagnostics) {
+	return lookupObject(func() ([]ServiceVariable, diag.Diagnostics) {
+		return ServiceVariablesApiCall(client, serviceId)
+	}, func(variable ServiceVariable) bool {
+		return variable.Name == variableName
+	})
+}
+
+// ResourceServiceVariableRead differs from the data source in that a variable deleted outside of
+// Terraform is removed from state instead of failing, so that Terraform proposes to recreate it
+func ResourceServiceVariableRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	serviceId := d.Get("service_id").(int)
 	variableName := d.Get("variable_name").(string)
-	variableValue := d.Get("value").(string)
-	found := false
 
-	serviceVariables, err := ServiceVariablesApiCall(serviceId)
+	variable, err := LookupServiceVariable(client, serviceId, variableName)
 	if err != nil {
 		return err
 	}
-
-	for _, variable := range serviceVariables {
-		if variableName == variable.Name {
-			found = true
-			err = UpdateServiceVariable(variable.Id, variableValue)
-			if err != nil {
-				return err
-			}
-			break
-		}
+	if variable == nil {
+		return removeFromState(d, "variable %s no longer exists for the service %d", variableName, serviceId)
 	}
 
-	if !found {
+	_ = d.Set("service_id", serviceId)
+	_ = d.Set("variable_name", variable.Name)
+	_ = d.Set("value", variable.Value)
+	return nil
+}
+
+func ResourceServiceVariableUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	serviceId := d.Get("service_id").(int)
+	variableName := d.Get("variable_name").(string)
+	variableValue := d.Get("value").(string)
+
+	variable, err := LookupServiceVariable(client, serviceId, variableName)
+	if err != nil {
+		return err
+	}
+	if variable == nil {
 		return diag.Errorf("no variable %s configured for the service %d", variableName, serviceId)
 	}
 
-	return nil
+	return UpdateServiceVariable(client, variable.Id, variableValue)
 }
 
-func ResourceServiceVariableDelete(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func ResourceServiceVariableDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	serviceId := d.Get("service_id").(int)
 	variableName := d.Get("variable_name").(string)
-	found := false
 
-	serviceVariables, err := ServiceVariablesApiCall(serviceId)
+	variable, err := LookupServiceVariable(client, serviceId, variableName)
 	if err != nil {
 		return err
 	}
+	if variable == nil {
+		// Already deleted outside of Terraform
+		return nil
+	}
 
-	for _, variable := range serviceVariables {
-		if variableName == variable.Name {
-			found = true
-			err = DeleteServiceVariable(variable.Id)
+	return DeleteServiceVariable(client, variable.Id)
+}
+
+// ResourceServiceVariableImport accepts the resource ID (`service-variable:<service_id>:<variable_name>`),
+// as well as `<service_id>/<variable_name>` and `<fleet ID or slug>/<service name>/<variable_name>`
+func ResourceServiceVariableImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	var serviceId int
+	var variableName string
+
+	if strings.HasPrefix(d.Id(), "service-variable:") {
+		parts := strings.SplitN(strings.TrimPrefix(d.Id(), "service-variable:"), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid import ID %q, expected service-variable:<service_id>:<variable_name>", d.Id())
+		}
+		id, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid service ID %q in import ID %q", parts[0], d.Id())
+		}
+		serviceId, variableName = id, parts[1]
+	} else {
+		parts := strings.Split(d.Id(), "/")
+		if id, err := strconv.Atoi(parts[0]); err == nil && len(parts) == 2 {
+			serviceId, variableName = id, parts[1]
+		} else if len(parts) >= 3 {
+			fleet := strings.Join(parts[:len(parts)-2], "/")
+			serviceName := parts[len(parts)-2]
+			variableName = parts[len(parts)-1]
+
+			fleetId, err := resolveFleetImportId(client, fleet)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			break
+			services, diags := DescribeServices(client, fleetId)
+			if diags.HasError() {
+				return nil, diagsToError(diags)
+			}
+			for _, service := range services {
+				if service.Name == serviceName {
+					serviceId = service.Id
+					break
+				}
+			}
+			if serviceId == 0 {
+				return nil, fmt.Errorf("no service %s found in the fleet %s", serviceName, fleet)
+			}
+		} else {
+			return nil, fmt.Errorf("invalid import ID %q, expected <service_id>/<variable_name> or <fleet ID or slug>/<service name>/<variable_name>", d.Id())
 		}
 	}
 
-	if !found {
-		return diag.Errorf("no variable %s configured for the service %d", variableName, serviceId)
-	}
-
-	return nil
-
+	_ = d.Set("service_id", serviceId)
+	_ = d.Set("variable_name", variableName)
+	d.SetId(GetSingularServiceVariableId(serviceId, variableName))
+	return []*schema.ResourceData{d}, nil
 }
 
-func CreateServiceVariable(serviceId int, variableName string, variableValue string) diag.Diagnostics {
-	res, err := client.client.R().
-		SetBody(map[string]interface{}{
+func CreateServiceVariable(client *api.Client, serviceId int, variableName string, variableValue string) diag.Diagnostics {
+	err := client.RetryCreate(func() error {
+		_, err := api.Create[ServiceVariable](client, api.ServiceEnvironmentVariable, map[string]interface{}{
 			"service": serviceId,
 			"name":    variableName,
 			"value":   variableValue,
-		}).
-		Post("/v7/service_environment_variable")
-
+		})
+		return err
+	}, func() (bool, error) {
+		variable, diags := LookupServiceVariable(client, serviceId, variableName)
+		return variable != nil, diagsToError(diags)
+	})
 	if err != nil {
-		return diag.FromErr(err)
-	}
-
-	if !is200Level(res.StatusCode()) {
-		return diag.FromErr(fmt.Errorf("error creating service variable with statuscode %d", res.StatusCode()))
+		return diag.Errorf("error creating service variable: %s", err)
 	}
 	return nil
 }
 
-func UpdateServiceVariable(serviceVariableId int, variableValue string) diag.Diagnostics {
-	res, err := client.client.R().
-		SetBody(map[string]interface{}{
-			"value": variableValue,
-		}).
-		Patch(fmt.Sprintf("/v7/service_environment_variable(%d)", serviceVariableId))
-	if err != nil {
-		return diag.FromErr(err)
-	}
-
-	if !is200Level(res.StatusCode()) {
-		return diag.FromErr(fmt.Errorf("error updating service variable with statuscode %d", res.StatusCode()))
+func UpdateServiceVariable(client *api.Client, serviceVariableId int, variableValue string) diag.Diagnostics {
+	if err := client.Patch(api.ServiceEnvironmentVariable.ID(serviceVariableId), map[string]interface{}{
+		"value": variableValue,
+	}); err != nil {
+		return diag.Errorf("error updating service variable: %s", err)
 	}
-
 	return nil
 }
 
-func DeleteServiceVariable(serviceVariableId int) diag.Diagnostics {
-	res, err := client.client.R().Delete(fmt.Sprintf("/v7/service_environment_variable(%d)", serviceVariableId))
-	if err != nil {
-		return diag.FromErr(err)
-	}
-
-	if !is200Level(res.StatusCode()) {
-		return diag.FromErr(fmt.Errorf("error deleting service variable with statuscode %d", res.StatusCode()))
+func DeleteServiceVariable(client *api.Client, serviceVariableId int) diag.Diagnostics {
+	if err := client.Delete(api.ServiceEnvironmentVariable.ID(serviceVariableId)); err != nil {
+		return diag.Errorf("error deleting service variable: %s", err)
 	}
 	return nil
 }
@@ -2,10 +2,10 @@ package balena
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
 )
 
 type Service struct {
@@ -14,10 +14,6 @@ type Service struct {
 	Created string `json:"created_at"`
 }
 
-type ServicesResponse struct {
-	Services []Service `json:"d"`
-}
-
 func GetServicesId(fleetId int) string {
 	return fmt.Sprintf("services:%d", fleetId)
 }
@@ -57,29 +53,19 @@ func getServicesDataSourceSchema() map[string]*schema.Schema {
 	}
 }
 
-func DescribeServices(fleetId int) ([]Service, diag.Diagnostics) {
-	fleet, err := FetchFleet("", fleetId)
+func DescribeServices(client *api.Client, fleetId int) ([]Service, diag.Diagnostics) {
+	services, err := api.Get[Service](client, api.Service.All().Filter(api.Eq("application", fleetId)))
 	if err != nil {
-		return nil, err
-	}
-
-	endpoint := fmt.Sprintf("/v7/service?$filter=%s", fmt.Sprintf("application/app_name eq '%s'", fleet.AppName))
-	res, _ := client.client.R().Get(endpoint)
-	if !is200Level(res.StatusCode()) {
-		return nil, diag.FromErr(fmt.Errorf("error retrieving Services: %s", res.Status()))
+		return nil, diag.Errorf("error retrieving Services: %s", err)
 	}
-
-	var servicesResponse ServicesResponse
-	if err := json.Unmarshal(res.Body(), &servicesResponse); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("failed to unmarshal response from Balena services API: %w", err))
-	}
-
-	return servicesResponse.Services, nil
+	return services, nil
 }
 
-func GetServicesDataSource(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+func GetServicesDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
 	fleetId := d.Get("fleet_id").(int)
-	services, err := DescribeServices(fleetId)
+	services, err := DescribeServices(client, fleetId)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,260 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"golang.org/x/crypto/ssh"
+	"strings"
+)
+
+// SshKey is the format the public SSH keys of users are returned from the Balena API
+type SshKey struct {
+	Id        int       `json:"id"`
+	Title     string    `json:"title"`
+	PublicKey string    `json:"public_key"`
+	Created   string    `json:"created_at"`
+	User      IDWrapper `json:"user"`
+}
+
+func GetSshKeyId(sshKeyId int) string {
+	return fmt.Sprintf("ssh-key:%d", sshKeyId)
+}
+
+// getSshKeyAttributesSchema the computed attributes of an SSH key, shared by the elements of `balena_ssh_keys`
+func getSshKeyAttributesSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"key_id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The ID of the key.",
+		},
+		"title": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The title of the key.",
+		},
+		"public_key": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The public key, in the OpenSSH authorized keys format.",
+		},
+		"fingerprint": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The SHA256 fingerprint of the key, as shown by `ssh-keygen -l`.",
+		},
+		"created": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The time the key was registered, represented as a string in ISO-Format.",
+		},
+	}
+}
+
+func dataSourceSshKeys() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: GetSshKeysDataSource,
+		Description: "List the public SSH keys registered for the user the provider is authenticated as.",
+		Schema: map[string]*schema.Schema{
+			"ssh_keys": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: getSshKeyAttributesSchema(),
+				},
+			},
+		},
+	}
+}
+
+func resourceSshKey() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceSshKeyCreate,
+		ReadContext:   ResourceSshKeyRead,
+		DeleteContext: ResourceSshKeyDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceSshKeyImport,
+		},
+		Description: "Register a public SSH key for the user the provider is authenticated as, granting access to devices through `balena ssh`. " +
+			"Destroying the resource revokes the access.",
+		Schema: map[string]*schema.Schema{
+			"title": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The title of the key.",
+			},
+			"public_key": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateFunc:     validateSshPublicKey,
+				DiffSuppressFunc: suppressSshPublicKeyWhitespace,
+				Description:      "The public key, in the OpenSSH authorized keys format, e.g. the contents of `~/.ssh/id_ed25519.pub`.",
+			},
+			"key_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the key.",
+			},
+			"fingerprint": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The SHA256 fingerprint of the key, as shown by `ssh-keygen -l`.",
+			},
+			"created": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the key was registered, represented as a string in ISO-Format.",
+			},
+		},
+	}
+}
+
+// sshKeyFingerprint parses an OpenSSH public key and returns its SHA256 fingerprint
+func sshKeyFingerprint(publicKey string) (string, error) {
+	key, _, _, rest, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return "", fmt.Errorf("not an OpenSSH public key: %w", err)
+	}
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return "", fmt.Errorf("expected a single OpenSSH public key")
+	}
+	return ssh.FingerprintSHA256(key), nil
+}
+
+func validateSshPublicKey(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := sshKeyFingerprint(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("`%s` is invalid: %w", k, err))
+	}
+	return
+}
+
+func suppressSshPublicKeyWhitespace(_, old, new string, _ *schema.ResourceData) bool {
+	return strings.TrimSpace(old) == strings.TrimSpace(new)
+}
+
+// DescribeSshKeys lists the public SSH keys matching an OData filter
+func DescribeSshKeys(client *api.Client, filter api.Filter) ([]SshKey, diag.Diagnostics) {
+	sshKeys, err := api.Get[SshKey](client, api.UserHasPublicKey.All().
+		Select("id", "title", "public_key", "created_at", "user").
+		Filter(filter).
+		OrderBy("created_at desc"))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving SSH Keys: %s", err)
+	}
+	return sshKeys, nil
+}
+
+// FetchAuthenticatedUser retrieves the user the credentials of the provider belong to
+func FetchAuthenticatedUser(client *api.Client) (*User, diag.Diagnostics) {
+	whoAmI, err := FetchWhoAmI(client)
+	if err != nil {
+		return nil, err
+	}
+	if whoAmI.Username == "" {
+		return nil, diag.Errorf("the credentials of the provider do not belong to a user")
+	}
+	return FetchUser(client, whoAmI.Username)
+}
+
+// flattenSshKey converts an SSH key to the attributes of `getSshKeyAttributesSchema`
+func flattenSshKey(sshKey SshKey) map[string]interface{} {
+	// Keys registered through the dashboard are validated by Balena, so a parse error only leaves the fingerprint empty
+	fingerprint, _ := sshKeyFingerprint(sshKey.PublicKey)
+	return map[string]interface{}{
+		"key_id":      sshKey.Id,
+		"title":       sshKey.Title,
+		"public_key":  sshKey.PublicKey,
+		"fingerprint": fingerprint,
+		"created":     sshKey.Created,
+	}
+}
+
+func GetSshKeysDataSource(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	user, err := FetchAuthenticatedUser(client)
+	if err != nil {
+		return err
+	}
+
+	sshKeys, err := DescribeSshKeys(client, api.Eq("user", user.Id))
+	if err != nil {
+		return err
+	}
+
+	response := make([]map[string]interface{}, 0)
+	for _, sshKey := range sshKeys {
+		response = append(response, flattenSshKey(sshKey))
+	}
+	if err := d.Set("ssh_keys", response); err != nil {
+		return diag.Errorf("failed to set ssh_keys: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("ssh-keys:%d", user.Id))
+	return nil
+}
+
+func ResourceSshKeyCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	user, err := FetchAuthenticatedUser(client)
+	if err != nil {
+		return err
+	}
+
+	sshKeyId, err := CreateObject(client, api.UserHasPublicKey, map[string]interface{}{
+		"title":      d.Get("title").(string),
+		"public_key": strings.TrimSpace(d.Get("public_key").(string)),
+		"user":       user.Id,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetSshKeyId(sshKeyId))
+	return ResourceSshKeyRead(ctx, d, m)
+}
+
+func ResourceSshKeyRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	sshKeyId, parseErr := ParseNumericId("ssh-key", d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	sshKeys, err := DescribeSshKeys(client, api.Eq("id", sshKeyId))
+	if err != nil {
+		return err
+	}
+	if len(sshKeys) == 0 {
+		return removeFromState(d, "ssh key %d no longer exists", sshKeyId)
+	}
+
+	for resourceAttribute, value := range flattenSshKey(sshKeys[0]) {
+		_ = d.Set(resourceAttribute, value)
+	}
+	return nil
+}
+
+func ResourceSshKeyDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return DeleteObject(client, api.UserHasPublicKey, d.Get("key_id").(int))
+}
+
+// ResourceSshKeyImport accepts the resource ID (`ssh-key:<key_id>`) as well as a bare key ID
+func ResourceSshKeyImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	sshKeyId, err := ParseNumericId("ssh-key", d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(GetSshKeyId(sshKeyId))
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,69 @@
+package balena
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+)
+
+// existingTag is the part of a tag needed to converge it, regardless of
+// whether it is attached to a device, a fleet or a release
+type existingTag struct {
+	id    int
+	value string
+}
+
+// ConvergeTags creates, patches and deletes the tags of a single parent so that they match `desired`
+//
+//	resource is the tag resource in the Balena API, e.g. `device_tag`
+//	parentField is the field linking a tag to its parent, e.g. `device`
+func ConvergeTags(client *api.Client, resource api.Resource, parentField string, parentId int, existing map[string]existingTag, desired map[string]string) diag.Diagnostics {
+	for key, tag := range existing {
+		if _, ok := desired[key]; !ok {
+			if err := DeleteTag(client, resource, tag.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key, value := range desired {
+		tag, ok := existing[key]
+		if !ok {
+			if err := CreateTag(client, resource, parentField, parentId, key, value); err != nil {
+				return err
+			}
+		} else if tag.value != value {
+			if err := UpdateTag(client, resource, tag.id, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func CreateTag(client *api.Client, resource api.Resource, parentField string, parentId int, tagKey string, tagValue string) diag.Diagnostics {
+	if _, err := api.Create[createdObject](client, resource, map[string]interface{}{
+		parentField: parentId,
+		"tag_key":   tagKey,
+		"value":     tagValue,
+	}); err != nil {
+		return diag.Errorf("error creating %s %s: %s", resource, tagKey, err)
+	}
+	return nil
+}
+
+func UpdateTag(client *api.Client, resource api.Resource, tagId int, tagValue string) diag.Diagnostics {
+	if err := client.Patch(resource.ID(tagId), map[string]interface{}{
+		"value": tagValue,
+	}); err != nil {
+		return diag.Errorf("error updating %s: %s", resource, err)
+	}
+	return nil
+}
+
+func DeleteTag(client *api.Client, resource api.Resource, tagId int) diag.Diagnostics {
+	if err := client.Delete(resource.ID(tagId)); err != nil && !api.IsNotFound(err) {
+		return diag.Errorf("error deleting %s: %s", resource, err)
+	}
+	return nil
+}
@@ -0,0 +1,445 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"strconv"
+	"strings"
+)
+
+type Team struct {
+	Id           int       `json:"id"`
+	Name         string    `json:"name"`
+	Organization IDWrapper `json:"belongs_to__organization"`
+	Created      string    `json:"created_at"`
+}
+
+// TeamMembership is the format team memberships are returned from the Balena API when expanding their user
+type TeamMembership struct {
+	Id   int       `json:"id"`
+	Team IDWrapper `json:"is_member_of__team"`
+	User []User    `json:"user"`
+}
+
+// TeamApplicationAccess is the format team application accesses are returned from the Balena API when expanding their role
+type TeamApplicationAccess struct {
+	Id      int       `json:"id"`
+	Team    IDWrapper `json:"team"`
+	FleetId IDWrapper `json:"grants_access_to__application"`
+	Role    []Role    `json:"application_membership_role"`
+}
+
+func GetTeamId(teamId int) string {
+	return fmt.Sprintf("team:%d", teamId)
+}
+
+func GetTeamMembershipId(membershipId int) string {
+	return fmt.Sprintf("team-membership:%d", membershipId)
+}
+
+func GetTeamApplicationAccessId(accessId int) string {
+	return fmt.Sprintf("team-application-access:%d", accessId)
+}
+
+func resourceTeam() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceTeamCreate,
+		ReadContext:   ResourceTeamRead,
+		UpdateContext: ResourceTeamUpdate,
+		DeleteContext: ResourceTeamDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceTeamImport,
+		},
+		Description: "Create and manage a team of an organization. Grant the team access to fleets with `balena_team_application_access`.",
+		Schema: map[string]*schema.Schema{
+			"organization_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the organization the team belongs to.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the team, unique within the organization.",
+			},
+			"team_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the team.",
+			},
+			"created": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Timestamp of when the team was created, represented as an ISO-Format string.",
+			},
+		},
+	}
+}
+
+func resourceTeamMembership() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceTeamMembershipCreate,
+		ReadContext:   ResourceTeamMembershipRead,
+		DeleteContext: ResourceTeamMembershipDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceTeamMembershipImport,
+		},
+		Description: "Add a member of an organization to one of its teams.",
+		Schema: map[string]*schema.Schema{
+			"team_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the team.",
+			},
+			"username": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The username of the member. The user must already be a member of the organization of the team.",
+			},
+			"membership_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the team membership.",
+			},
+			"user_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the member.",
+			},
+		},
+	}
+}
+
+func resourceTeamApplicationAccess() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: ResourceTeamApplicationAccessCreate,
+		ReadContext:   ResourceTeamApplicationAccessRead,
+		UpdateContext: ResourceTeamApplicationAccessUpdate,
+		DeleteContext: ResourceTeamApplicationAccessDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: ResourceTeamApplicationAccessImport,
+		},
+		Description: "Grant the members of a team access to a fleet with a role.",
+		Schema: map[string]*schema.Schema{
+			"team_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the team.",
+			},
+			"fleet_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The ID of the fleet the team gets access to.",
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice(applicationMembershipRoles, false),
+				Description:  "The role of the team in the fleet, one of `" + strings.Join(applicationMembershipRoles, "`, `") + "`.",
+			},
+			"access_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the team application access.",
+			},
+		},
+	}
+}
+
+func DescribeTeams(client *api.Client, filter api.Filter) ([]Team, diag.Diagnostics) {
+	teams, err := api.Get[Team](client, api.Team.All().
+		Select("id", "name", "belongs_to__organization", "created_at").
+		Filter(filter))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Teams: %s", err)
+	}
+	return teams, nil
+}
+
+func DescribeTeamMemberships(client *api.Client, filter api.Filter) ([]TeamMembership, diag.Diagnostics) {
+	memberships, err := api.Get[TeamMembership](client, api.TeamMembership.All().
+		Select("id", "is_member_of__team").
+		Expand("user", api.Options().Select("id", "username")).
+		Filter(filter))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Team Memberships: %s", err)
+	}
+	return memberships, nil
+}
+
+func DescribeTeamApplicationAccesses(client *api.Client, filter api.Filter) ([]TeamApplicationAccess, diag.Diagnostics) {
+	accesses, err := api.Get[TeamApplicationAccess](client, api.TeamApplicationAccess.All().
+		Select("id", "team", "grants_access_to__application").
+		Expand("application_membership_role", api.Options().Select("id", "name")).
+		Filter(filter))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving Team Application Accesses: %s", err)
+	}
+	return accesses, nil
+}
+
+func ResourceTeamCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	teamId, err := CreateObject(client, api.Team, map[string]interface{}{
+		"name":                     d.Get("name").(string),
+		"belongs_to__organization": d.Get("organization_id").(int),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetTeamId(teamId))
+	return ResourceTeamRead(ctx, d, m)
+}
+
+func ResourceTeamRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	teamId, parseErr := ParseNumericId("team", d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	teams, err := DescribeTeams(client, api.Eq("id", teamId))
+	if err != nil {
+		return err
+	}
+	if len(teams) == 0 {
+		return removeFromState(d, "team %d no longer exists", teamId)
+	}
+
+	_ = d.Set("team_id", teams[0].Id)
+	_ = d.Set("organization_id", teams[0].Organization.ID)
+	_ = d.Set("name", teams[0].Name)
+	_ = d.Set("created", teams[0].Created)
+	return nil
+}
+
+func ResourceTeamUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	if d.HasChange("name") {
+		if err := UpdateObject(client, api.Team, d.Get("team_id").(int), map[string]interface{}{
+			"name": d.Get("name").(string),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return ResourceTeamRead(ctx, d, m)
+}
+
+func ResourceTeamDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return DeleteObject(client, api.Team, d.Get("team_id").(int))
+}
+
+// ResourceTeamImport accepts the resource ID (`team:<team_id>`) as well as a bare team ID
+func ResourceTeamImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	teamId, err := ParseNumericId("team", d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(GetTeamId(teamId))
+	return []*schema.ResourceData{d}, nil
+}
+
+func ResourceTeamMembershipCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	user, err := FetchUser(client, d.Get("username").(string))
+	if err != nil {
+		return err
+	}
+
+	membershipId, err := CreateObject(client, api.TeamMembership, map[string]interface{}{
+		"user":               user.Id,
+		"is_member_of__team": d.Get("team_id").(int),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetTeamMembershipId(membershipId))
+	return ResourceTeamMembershipRead(ctx, d, m)
+}
+
+func ResourceTeamMembershipRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	membershipId, parseErr := ParseNumericId("team-membership", d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	memberships, err := DescribeTeamMemberships(client, api.Eq("id", membershipId))
+	if err != nil {
+		return err
+	}
+	if len(memberships) == 0 {
+		return removeFromState(d, "team membership %d no longer exists", membershipId)
+	}
+
+	membership := memberships[0]
+	_ = d.Set("membership_id", membership.Id)
+	_ = d.Set("team_id", membership.Team.ID)
+	if len(membership.User) > 0 {
+		_ = d.Set("user_id", membership.User[0].Id)
+		_ = d.Set("username", membership.User[0].Username)
+	}
+	return nil
+}
+
+func ResourceTeamMembershipDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return DeleteObject(client, api.TeamMembership, d.Get("membership_id").(int))
+}
+
+// ResourceTeamMembershipImport accepts the resource ID (`team-membership:<membership_id>`)
+// as well as `<team_id>/<username>`
+func ResourceTeamMembershipImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	if strings.HasPrefix(d.Id(), "team-membership:") {
+		return []*schema.ResourceData{d}, nil
+	}
+
+	parts := strings.SplitN(d.Id(), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid import ID %q, expected <team_id>/<username>", d.Id())
+	}
+	teamId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid team ID %q in import ID %q", parts[0], d.Id())
+	}
+
+	memberships, diags := DescribeTeamMemberships(client, api.And(api.Eq("is_member_of__team", teamId), api.Eq("user/username", parts[1])))
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+	if len(memberships) == 0 {
+		return nil, fmt.Errorf("%s is not a member of the team %d", parts[1], teamId)
+	}
+
+	d.SetId(GetTeamMembershipId(memberships[0].Id))
+	return []*schema.ResourceData{d}, nil
+}
+
+func ResourceTeamApplicationAccessCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	roleId, err := FetchRoleId(client, api.ApplicationMembershipRole, d.Get("role").(string))
+	if err != nil {
+		return err
+	}
+
+	accessId, err := CreateObject(client, api.TeamApplicationAccess, map[string]interface{}{
+		"team":                          d.Get("team_id").(int),
+		"grants_access_to__application": d.Get("fleet_id").(int),
+		"application_membership_role":   roleId,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(GetTeamApplicationAccessId(accessId))
+	return ResourceTeamApplicationAccessRead(ctx, d, m)
+}
+
+func ResourceTeamApplicationAccessRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	accessId, parseErr := ParseNumericId("team-application-access", d.Id())
+	if parseErr != nil {
+		return diag.FromErr(parseErr)
+	}
+
+	accesses, err := DescribeTeamApplicationAccesses(client, api.Eq("id", accessId))
+	if err != nil {
+		return err
+	}
+	if len(accesses) == 0 {
+		return removeFromState(d, "team application access %d no longer exists", accessId)
+	}
+
+	access := accesses[0]
+	_ = d.Set("access_id", access.Id)
+	_ = d.Set("team_id", access.Team.ID)
+	_ = d.Set("fleet_id", access.FleetId.ID)
+	if len(access.Role) > 0 {
+		_ = d.Set("role", access.Role[0].Name)
+	}
+	return nil
+}
+
+func ResourceTeamApplicationAccessUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	if d.HasChange("role") {
+		roleId, err := FetchRoleId(client, api.ApplicationMembershipRole, d.Get("role").(string))
+		if err != nil {
+			return err
+		}
+
+		if err := UpdateObject(client, api.TeamApplicationAccess, d.Get("access_id").(int), map[string]interface{}{
+			"application_membership_role": roleId,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return ResourceTeamApplicationAccessRead(ctx, d, m)
+}
+
+func ResourceTeamApplicationAccessDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*api.Client)
+
+	return DeleteObject(client, api.TeamApplicationAccess, d.Get("access_id").(int))
+}
+
+// ResourceTeamApplicationAccessImport accepts the resource ID (`team-application-access:<access_id>`)
+// as well as `<team_id>/<fleet_id>`
+func ResourceTeamApplicationAccessImport(_ context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*api.Client)
+
+	if strings.HasPrefix(d.Id(), "team-application-access:") {
+		return []*schema.ResourceData{d}, nil
+	}
+
+	parts := strings.SplitN(d.Id(), "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid import ID %q, expected <team_id>/<fleet_id>", d.Id())
+	}
+	teamId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid team ID %q in import ID %q", parts[0], d.Id())
+	}
+	fleetId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid fleet ID %q in import ID %q", parts[1], d.Id())
+	}
+
+	accesses, diags := DescribeTeamApplicationAccesses(client, api.And(api.Eq("team", teamId), api.Eq("grants_access_to__application", fleetId)))
+	if diags.HasError() {
+		return nil, diagsToError(diags)
+	}
+	if len(accesses) == 0 {
+		return nil, fmt.Errorf("the team %d has no access to the fleet %d", teamId, fleetId)
+	}
+
+	d.SetId(GetTeamApplicationAccessId(accesses[0].Id))
+	return []*schema.ResourceData{d}, nil
+}
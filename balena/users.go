@@ -0,0 +1,26 @@
+package balena
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+)
+
+type User struct {
+	Id       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// FetchUser retrieves a user by username. Balena only exposes users sharing an organization with the provider credentials
+func FetchUser(client *api.Client, username string) (*User, diag.Diagnostics) {
+	users, err := api.Get[User](client, api.User.All().
+		Select("id", "username").
+		Filter(api.Eq("username", username)))
+	if err != nil {
+		return nil, diag.Errorf("error retrieving User: %s", err)
+	}
+
+	if len(users) == 0 {
+		return nil, diag.Errorf("no user %s found", username)
+	}
+	return &users[0], nil
+}
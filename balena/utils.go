@@ -1,9 +1,91 @@
 package balena
 
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/kassett/terraform-provider-balena/balena/api"
+	"log"
+	"strconv"
+	"strings"
+)
+
 type IDWrapper struct {
 	ID int `json:"__id"`
 }
 
-func is200Level(statusCode int) bool {
-	return statusCode >= 200 && statusCode < 300
+// lookupObject finds the first of the objects returned by describe that matches, returning nil
+// without an error when none does
+func lookupObject[T any](describe func() ([]T, diag.Diagnostics), match func(T) bool) (*T, diag.Diagnostics) {
+	objects, err := describe()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		if match(object) {
+			return &object, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// removeFromState drops an object deleted outside of Terraform from state during a read,
+// so that Terraform proposes to recreate it instead of failing
+func removeFromState(d *schema.ResourceData, format string, args ...interface{}) diag.Diagnostics {
+	log.Printf("[WARN] "+format+", removing it from state", args...)
+	d.SetId("")
+	return nil
+}
+
+// diagsToError flattens diagnostics into a single error for SDK callbacks,
+// such as importers, that cannot return diagnostics
+func diagsToError(diags diag.Diagnostics) error {
+	var errs []error
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			errs = append(errs, errors.New(d.Summary))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseNumericId reverses the `<prefix>:<id>` resource IDs of resources identified by a numeric Balena ID
+func ParseNumericId(prefix string, id string) (int, error) {
+	numericId, err := strconv.Atoi(strings.TrimPrefix(id, prefix+":"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q, expected the format %s:<id>", id, prefix)
+	}
+	return numericId, nil
+}
+
+// createdObject is the part of a created object needed to address it afterwards
+type createdObject struct {
+	Id int `json:"id"`
+}
+
+// CreateObject posts a new object to a Balena API resource, e.g. `team`, and returns its ID
+func CreateObject(client *api.Client, resource api.Resource, body map[string]interface{}) (int, diag.Diagnostics) {
+	created, err := api.Create[createdObject](client, resource, body)
+	if err != nil {
+		return 0, diag.Errorf("error creating %s: %s", resource, err)
+	}
+	return created.Id, nil
+}
+
+func UpdateObject(client *api.Client, resource api.Resource, id int, body map[string]interface{}) diag.Diagnostics {
+	if err := client.Patch(resource.ID(id), body); err != nil {
+		return diag.Errorf("error updating %s: %s", resource, err)
+	}
+	return nil
+}
+
+// DeleteObject deletes an object from a Balena API resource, tolerating objects already deleted outside of Terraform
+func DeleteObject(client *api.Client, resource api.Resource, id int) diag.Diagnostics {
+	if err := client.Delete(resource.ID(id)); err != nil && !api.IsNotFound(err) {
+		return diag.Errorf("error deleting %s: %s", resource, err)
+	}
+	return nil
 }
@@ -0,0 +1,8 @@
+data "balena_api_keys" "this" {}
+
+output "expiring_api_keys" {
+  value = [
+    for key in data.balena_api_keys.this.api_keys : key.name
+    if key.expiry_date != "" && timecmp(key.expiry_date, timeadd(plantimestamp(), "720h")) < 0
+  ]
+}
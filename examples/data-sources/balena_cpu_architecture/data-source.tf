@@ -0,0 +1,14 @@
+data "balena_cpu_architecture" "image" {
+  slug = "aarch64"
+}
+
+data "balena_device_type" "this" {
+  slug = "raspberrypi4-64"
+
+  lifecycle {
+    postcondition {
+      condition     = contains(data.balena_cpu_architecture.image.device_types, self.slug)
+      error_message = "The device type does not match the architecture of the image."
+    }
+  }
+}
@@ -0,0 +1,9 @@
+data "balena_device_type" "rpi4" {
+  slug = "raspberrypi4-64"
+}
+
+resource "balena_fleet" "this" {
+  app_name        = "my-fleet"
+  organization_id = 12345
+  device_type_id  = data.balena_device_type.rpi4.device_type_id
+}
@@ -0,0 +1,10 @@
+data "balena_devices" "offline" {
+  fleet_id  = 123456
+  is_online = false
+  tag_key   = "site"
+  tag_value = "warehouse-1"
+}
+
+output "offline_devices" {
+  value = [for device in data.balena_devices.offline.devices : device.device_name]
+}
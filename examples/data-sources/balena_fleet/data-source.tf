@@ -0,0 +1,8 @@
+data "balena_fleet" "by_slug" {
+  slug = "my_org/my_fleet"
+}
+
+data "balena_fleet" "by_name" {
+  organization = "my_org"
+  app_name     = "My Fleet"
+}
@@ -0,0 +1,13 @@
+data "balena_fleets" "production" {
+  organization = "my_org"
+  name_prefix  = "prod-"
+  archived     = false
+}
+
+resource "balena_fleet_variable" "log_level" {
+  for_each = { for fleet in data.balena_fleets.production.fleets : fleet.slug => fleet }
+
+  fleet_id      = each.value.fleet_id
+  variable_name = "LOG_LEVEL"
+  value         = "info"
+}
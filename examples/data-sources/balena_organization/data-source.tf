@@ -0,0 +1,3 @@
+data "balena_organization" "this" {
+  handle = "my_org"
+}
@@ -0,0 +1,3 @@
+# An API key can be imported by its resource ID or its key ID. The key itself cannot be recovered.
+terraform import balena_api_key.this api-key:89012
+terraform import balena_api_key.this 89012
@@ -0,0 +1,2 @@
+# A device can be imported by its UUID
+terraform import balena_device.this a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4
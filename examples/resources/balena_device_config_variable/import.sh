@@ -0,0 +1,3 @@
+# A device configuration variable can be imported by its resource ID, or by the device UUID followed by the variable name
+terraform import balena_device_config_variable.this device-config-variable:a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4:BALENA_HOST_CONFIG_gpu_mem
+terraform import balena_device_config_variable.this a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4/BALENA_HOST_CONFIG_gpu_mem
@@ -0,0 +1,3 @@
+# A device tag can be imported by its resource ID, or by the device UUID followed by the tag key
+terraform import balena_device_tag.this device-tag:a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4:site
+terraform import balena_device_tag.this a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4/site
@@ -0,0 +1,2 @@
+# The tags of a device can be imported by the device UUID
+terraform import balena_device_tags.this a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4
@@ -0,0 +1,3 @@
+# A device variable can be imported by its resource ID, or by the device UUID followed by the variable name
+terraform import balena_device_variable.this device-variable:a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4:VARIABLE_NAME
+terraform import balena_device_variable.this a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4/VARIABLE_NAME
@@ -0,0 +1,3 @@
+# A fleet can be imported by its ID or by its slug
+terraform import balena_fleet.this 1234567
+terraform import balena_fleet.this my_org/my_fleet
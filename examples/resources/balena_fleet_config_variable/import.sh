@@ -0,0 +1,3 @@
+# A fleet configuration variable can be imported by its resource ID, or by the fleet ID or slug followed by the variable name
+terraform import balena_fleet_config_variable.this fleet-config-variable:1234567:BALENA_SUPERVISOR_POLL_INTERVAL
+terraform import balena_fleet_config_variable.this my_org/my_fleet/BALENA_SUPERVISOR_POLL_INTERVAL
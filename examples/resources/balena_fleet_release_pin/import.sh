@@ -0,0 +1,3 @@
+# The release pin of a fleet can be imported by the fleet ID or slug
+terraform import balena_fleet_release_pin.this 1234567
+terraform import balena_fleet_release_pin.this my_org/my_fleet
@@ -0,0 +1,3 @@
+# A fleet tag can be imported by its resource ID, or by the fleet ID or slug followed by the tag key
+terraform import balena_fleet_tag.this fleet-tag:1234567:environment
+terraform import balena_fleet_tag.this my_org/my_fleet/environment
@@ -0,0 +1,4 @@
+# A fleet variable can be imported by its resource ID, or by the fleet ID or slug followed by the variable name
+terraform import balena_fleet_variable.this fleet-variable:1234567:VARIABLE_NAME
+terraform import balena_fleet_variable.this 1234567/VARIABLE_NAME
+terraform import balena_fleet_variable.this my_org/my_fleet/VARIABLE_NAME
@@ -0,0 +1,3 @@
+# An organization membership can be imported by its resource ID, or by the organization ID followed by the username
+terraform import balena_organization_membership.this organization-membership:34567
+terraform import balena_organization_membership.this 12345/jane_doe
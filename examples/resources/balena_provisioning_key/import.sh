@@ -0,0 +1,3 @@
+# A provisioning key can be imported by its resource ID or its key ID. The key itself cannot be recovered.
+terraform import balena_provisioning_key.this provisioning-key:78901
+terraform import balena_provisioning_key.this 78901
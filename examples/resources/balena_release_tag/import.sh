@@ -0,0 +1,3 @@
+# A release tag can be imported by its resource ID, or by the release ID followed by the tag key
+terraform import balena_release_tag.this release-tag:2345678:approved
+terraform import balena_release_tag.this 2345678/approved
@@ -0,0 +1,4 @@
+# A device service variable can be imported by its resource ID, or by the device UUID,
+# the service ID or name and the variable name
+terraform import balena_sensitive_device_service_variable.this device-service-variable:a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4:7654321:VARIABLE_NAME
+terraform import balena_sensitive_device_service_variable.this a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4/my_service/VARIABLE_NAME
@@ -0,0 +1,6 @@
+# A service variable can be imported by its resource ID, by the service ID followed by the variable name,
+# or by the fleet ID or slug, service name and variable name
+terraform import balena_service_variable.this service-variable:7654321:VARIABLE_NAME
+terraform import balena_service_variable.this 7654321/VARIABLE_NAME
+terraform import balena_service_variable.this 1234567/my_service/VARIABLE_NAME
+terraform import balena_service_variable.this my_org/my_fleet/my_service/VARIABLE_NAME
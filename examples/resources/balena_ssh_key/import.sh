@@ -0,0 +1,3 @@
+# An SSH key can be imported by its resource ID or its key ID
+terraform import balena_ssh_key.this ssh-key:90123
+terraform import balena_ssh_key.this 90123
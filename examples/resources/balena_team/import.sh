@@ -0,0 +1,3 @@
+# A team can be imported by its resource ID or its team ID
+terraform import balena_team.this team:4567
+terraform import balena_team.this 4567
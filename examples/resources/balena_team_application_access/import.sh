@@ -0,0 +1,3 @@
+# A team application access can be imported by its resource ID, or by the team ID followed by the fleet ID
+terraform import balena_team_application_access.this team-application-access:6789
+terraform import balena_team_application_access.this 4567/123456
@@ -0,0 +1,3 @@
+# A team membership can be imported by its resource ID, or by the team ID followed by the username
+terraform import balena_team_membership.this team-membership:56789
+terraform import balena_team_membership.this 4567/jane_doe
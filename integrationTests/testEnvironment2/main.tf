@@ -27,4 +27,19 @@ data "balena_device_tags" "this" {
 
 output "device_tags" {
   value = data.balena_device_tags.this.tags
-}
\ No newline at end of file
+}
+data "balena_device_variables" "this" {
+  device_uuid = data.balena_device.this.uuid
+}
+
+data "balena_effective_variables" "this" {
+  device_uuid = data.balena_device.this.uuid
+}
+
+output "device_variables" {
+  value = data.balena_device_variables.this.variables
+}
+
+output "effective_variable_sources" {
+  value = data.balena_effective_variables.this.variable_sources
+}